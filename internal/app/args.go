@@ -15,14 +15,20 @@ type subcommand interface {
 }
 
 type cmdArgs struct {
-	Namespace     string       `arg:"-n,--namespace" help:"namespace to compare" default:"default"`
-	AllNamespaces bool         `arg:"-A,--all-namespaces" help:"If present, list the requested object(s) across all namespaces."`
-	Debug         bool         `arg:"-d,--debug" help:"enable debug output"`
-	Kubeconfig    string       `arg:"-k" help:"filename of kubeconfig to use"`
-	Compare       *compareArgs `arg:"subcommand:compare" help:"Compare pod requests to VPA recommendations"`
-	Mode          *modeArgs    `arg:"subcommand:mode" help:"Change mode on VPA-resource(s)"`
-	Suggest       *suggestArgs `arg:"subcommand:suggest" help:"Suggest YAML from a VPA-resource"`
-	Create        *createArgs  `arg:"subcommand:create" help:"Create a VPA-YAML from a pod"`
+	Namespace     string         `arg:"-n,--namespace" help:"namespace to compare" default:"default"`
+	AllNamespaces bool           `arg:"-A,--all-namespaces" help:"If present, list the requested object(s) across all namespaces."`
+	Debug         bool           `arg:"-d,--debug" help:"enable debug output"`
+	Kubeconfig    string         `arg:"-k" help:"filename of kubeconfig to use"`
+	Templates     string         `arg:"--templates,env:KUBECTL_VPA_TEMPLATES" help:"yaml-file with pod-template paths for custom kinds"`
+	Compare       *compareArgs   `arg:"subcommand:compare" help:"Compare pod requests to VPA recommendations"`
+	Mode          *modeArgs      `arg:"subcommand:mode" help:"Change mode on VPA-resource(s)"`
+	Undo          *undoArgs      `arg:"subcommand:undo" help:"Restore the mode of VPA-resource(s) from before the last mode-change"`
+	Reconcile     *reconcileArgs `arg:"subcommand:reconcile" help:"Restore the mode of VPA-resource(s) where a temporary mode (mode --for) has expired"`
+	Suggest       *suggestArgs   `arg:"subcommand:suggest" help:"Suggest YAML from a VPA-resource"`
+	Create        *createArgs    `arg:"subcommand:create" help:"Create a VPA-YAML from a pod"`
+	Describe      *describeArgs  `arg:"subcommand:describe" help:"Show the details of VPA-resource(s)"`
+	List          *listArgs      `arg:"subcommand:list" help:"List VPA-resources with their mode and health"`
+	Audit         *auditArgs     `arg:"subcommand:audit" help:"Find orphaned VPA-resources, and workloads without or with more than one VPA"`
 }
 
 type modeEnum int
@@ -30,17 +36,24 @@ type modeEnum int
 const (
 	modeOff modeEnum = iota + 1
 	modeInitial
+	modeRecreate
 	modeAuto
 
-	modeOffText     = "Off"
-	modeInitialText = "Initial"
-	modeAutoText    = "Auto"
+	modeOffText      = "Off"
+	modeInitialText  = "Initial"
+	modeRecreateText = "Recreate"
+	modeAutoText     = "Auto"
 )
 
+// allModes are all the UpdateModes of the VPA
+var allModes = []modeEnum{modeOff, modeInitial, modeRecreate, modeAuto}
+
 func (mode modeEnum) String() string {
 	switch mode {
 	case modeInitial:
 		return modeInitialText
+	case modeRecreate:
+		return modeRecreateText
 	case modeAuto:
 		return modeAutoText
 	default:
@@ -55,10 +68,46 @@ func (mode *modeEnum) UnmarshalText(b []byte) error {
 		*mode = modeOff
 	case "initial", "init":
 		*mode = modeInitial
+	case "recreate":
+		*mode = modeRecreate
 	case "auto":
 		*mode = modeAuto
 	default:
-		return fmt.Errorf("unknown mode: '%s', allowed values: Off, Initial & Auto", mode)
+		return fmt.Errorf("unknown mode: '%s', allowed values: Off, Initial, Recreate & Auto", s)
+	}
+	return nil
+}
+
+type dryRunEnum int
+
+const (
+	dryRunNone dryRunEnum = iota
+	dryRunClient
+	dryRunServer
+)
+
+func (dr dryRunEnum) String() string {
+	switch dr {
+	case dryRunClient:
+		return "client"
+	case dryRunServer:
+		return "server"
+	default:
+		return "none"
+	}
+}
+
+func (dr *dryRunEnum) UnmarshalText(b []byte) error {
+	s := strings.ToLower(string(b))
+	switch s {
+	case "none", "false":
+		*dr = dryRunNone
+	case "client":
+		*dr = dryRunClient
+	case "server":
+		*dr = dryRunServer
+	default:
+		return fmt.Errorf("unknown dry-run: '%s', allowed values: none, client & server", s)
 	}
 	return nil
 }
@@ -78,6 +127,15 @@ func ParseArgs() (subcommand, *cmdArgs, bool) {
 
 	if args.AllNamespaces {
 		args.Namespace = ""
+		if args.Suggest != nil {
+			args.Suggest.allNamespaces = true
+		}
+	}
+
+	if args.Templates != "" {
+		if err := loadTemplatePaths(args.Templates); err != nil {
+			pa.Fail(err.Error())
+		}
 	}
 
 	if pa.Subcommand() == nil {
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/ninlil/columns"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// problems found by audit
+const (
+	problemOrphaned  = "orphaned"  // a VPA without target, or where the target doesn't exist
+	problemUncovered = "uncovered" // a workload without a VPA
+	problemDuplicate = "duplicate" // a workload targeted by more than one VPA
+)
+
+type auditArgs struct {
+	Problems []string `arg:"-p,--problem,separate" help:"only report these problems: orphaned, uncovered or duplicate" placeholder:"PROBLEM"`
+	ExitCode bool     `arg:"--exit-code" help:"exit with 1 if any problem is found (i.e. for CI)"`
+
+	problems map[string]bool
+}
+
+// workloadData is a workload that can be targeted by a VPA
+type workloadData struct {
+	api       string
+	kind      string
+	namespace string
+	name      string
+	vpas      []string // names of the VPAs targeting the workload
+	owner     string   // key of the top-most owner, when the workload is controlled by another object (i.e. an operator)
+}
+
+// Key of the workload, same as vpaData.Key()
+func (w *workloadData) Key() string {
+	return fmt.Sprintf("%s:%s@%s/%s", apiGroup(w.api), strings.ToLower(w.kind), w.namespace, w.name)
+}
+
+// auditFinding is a problem found for a VPA or workload
+type auditFinding struct {
+	problem   string
+	namespace string
+	object    string
+	details   string
+}
+
+func (audit *auditArgs) Verify() error {
+	audit.problems = make(map[string]bool)
+	for _, p := range audit.Problems {
+		switch p = strings.ToLower(p); p {
+		case problemOrphaned, problemUncovered, problemDuplicate:
+			audit.problems[p] = true
+		default:
+			return fmt.Errorf("unknown problem: '%s', allowed values: orphaned, uncovered & duplicate", p)
+		}
+	}
+	if len(audit.problems) == 0 {
+		audit.problems[problemOrphaned] = true
+		audit.problems[problemUncovered] = true
+		audit.problems[problemDuplicate] = true
+	}
+	return nil
+}
+
+func (audit *auditArgs) Exec(k8 *k8client, args *cmdArgs) {
+	workloads, err := listWorkloads(k8, args.Namespace)
+	if err != nil {
+		panic(err)
+	}
+	byKey := make(map[string]*workloadData)
+	for _, w := range workloads {
+		byKey[w.Key()] = w
+	}
+
+	result, err := k8.VPAs(args.Namespace)
+	if err != nil {
+		panic(err)
+	}
+
+	var findings []auditFinding
+	targeted := make(map[string]bool)
+	for i := range result.Items {
+		v := &result.Items[i]
+		if finding := audit.checkTarget(k8, v, byKey); finding != nil {
+			findings = append(findings, *finding)
+		}
+		if target := v.Spec.TargetRef; target != nil {
+			ref := &workloadData{api: target.APIVersion, kind: target.Kind, namespace: v.Namespace, name: target.Name}
+			targeted[ref.Key()] = true
+		}
+	}
+
+	for _, w := range workloads {
+		object := fmt.Sprintf("%s/%s", strings.ToLower(w.kind), w.name)
+		switch {
+		case len(w.vpas) == 0 && w.owner != "" && targeted[w.owner]:
+			// covered by a VPA for the owner
+		case len(w.vpas) == 0:
+			findings = append(findings, auditFinding{problemUncovered, w.namespace, object, "no VPA targets this workload"})
+		case len(w.vpas) > 1:
+			findings = append(findings, auditFinding{problemDuplicate, w.namespace, object, "targeted by " + strings.Join(w.vpas, ", ")})
+		}
+	}
+
+	var shown int
+	cw := columns.New(os.Stdout, "< < < <")
+	cw.Headers("Problem", "Namespace", "Object", "Details")
+	cw.HeaderSeparator = true
+	for _, f := range findings {
+		if audit.problems[f.problem] {
+			cw.Write(f.problem, f.namespace, f.object, f.details)
+			shown++
+		}
+	}
+	if shown == 0 {
+		fmt.Println("no problems found")
+		return
+	}
+	cw.Sort(1, 2, 3)
+	cw.Flush()
+
+	if audit.ExitCode {
+		os.Exit(1)
+	}
+}
+
+// checkTarget finds the workload targeted by the VPA, returns a finding if there is none
+func (audit *auditArgs) checkTarget(k8 *k8client, v *vpa.VerticalPodAutoscaler, workloads map[string]*workloadData) *auditFinding {
+	object := "vpa/" + v.Name
+	target := v.Spec.TargetRef
+	if target == nil {
+		return &auditFinding{problemOrphaned, v.Namespace, object, "no targetRef"}
+	}
+
+	ref := &workloadData{api: target.APIVersion, kind: target.Kind, namespace: v.Namespace, name: target.Name}
+	if w, ok := workloads[ref.Key()]; ok {
+		w.vpas = append(w.vpas, v.Name)
+		return nil
+	}
+
+	details := fmt.Sprintf("target %s/%s not found", strings.ToLower(target.Kind), target.Name)
+	if !isAuditedKind(target.APIVersion, target.Kind) {
+		// other kinds are not listed, so look them up one by one
+		_, err := k8.ObjectMeta(v.Namespace, target.APIVersion, target.Kind, target.Name)
+		if err == nil {
+			return nil
+		}
+		details = fmt.Sprintf("target %s/%s: %v", strings.ToLower(target.Kind), target.Name, err)
+	}
+	return &auditFinding{problemOrphaned, v.Namespace, object, details}
+}
+
+// auditedKinds are the kinds of workloads listed by audit
+var auditedKinds = []struct {
+	api      string
+	kind     string
+	resource string
+}{
+	{"apps/v1", kindDeployment, "deployments"},
+	{"apps/v1", kindStatefulSet, "statefulsets"},
+	{"apps/v1", kindDaemonSet, "daemonsets"},
+	{"batch/v1", kindCronJob, "cronjobs"},
+}
+
+// isAuditedKind is true for the kinds listed by listWorkloads
+func isAuditedKind(apiVersion, kind string) bool {
+	for _, k := range auditedKinds {
+		if apiGroup(k.api) == apiGroup(apiVersion) && k.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// listWorkloads lists all workloads of the audited kinds
+func listWorkloads(k8 *k8client, ns string) ([]*workloadData, error) {
+	var workloads []*workloadData
+	for _, k := range auditedKinds {
+		gvr := schema.FromAPIVersionAndKind(k.api, k.kind).GroupVersion().WithResource(k.resource)
+		list, err := k8.metaClient.Resource(gvr).Namespace(ns).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s: %w", k.resource, err)
+		}
+		for i := range list.Items {
+			item := &list.Items[i]
+			w := &workloadData{api: k.api, kind: k.kind, namespace: item.Namespace, name: item.Name}
+			if owner := k8.Owner(item, k.api, k.kind); owner.Err == nil && (owner.Kind != k.kind || owner.Name != item.Name) {
+				ref := &workloadData{api: owner.APIVersion, kind: owner.Kind, namespace: item.Namespace, name: owner.Name}
+				w.owner = ref.Key()
+			}
+			workloads = append(workloads, w)
+		}
+	}
+
+	sort.SliceStable(workloads, func(i, j int) bool {
+		return workloads[i].Key() < workloads[j].Key()
+	})
+	return workloads, nil
+}
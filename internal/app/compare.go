@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/ninlil/ansi"
 	"github.com/ninlil/columns"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
 )
 
 type compareArgs struct {
@@ -24,48 +27,84 @@ type compareArgs struct {
 	Tail         int           `arg:"-t,--tail" help:"only print N last lines" default:"-1"`
 	Sort         []int         `arg:"-s,--sort,separate" help:"sort by column N (negative sorts descending)"`
 	Sum          bool          `arg:"-z,--sum" help:"add sums to relevant value columns"`
+	LimitRisk    bool          `arg:"-r,--limit-risk" help:"only containers whose (expected) limit is below the VPA upper bound"`
+	Format       *formatEnum   `arg:"-o,--output-format" help:"Select output format (wide, limits, json, yaml, csv, tsv, markdown)"`
+	Usage        bool          `arg:"-u,--usage" help:"add actual usage from metrics-server"`
+	Watch        bool          `arg:"-w,--watch" help:"keep the output current as pods and VPAs change"`
+	Interval     time.Duration `arg:"-i,--interval" help:"refresh interval for --watch when watches are not allowed" default:"5s"`
 	filter       compareFilter `arg:"-"`
+	previous     map[string]string
+	current      map[string]string
+	noMetrics    bool
+	unresolved   map[string]bool
 }
 
 type compareFilter struct {
-	filter      bool
-	showOff     bool
-	showInitial bool
-	showAuto    bool
+	filter bool
+	modes  map[string]bool
 }
 
 func (comp *compareArgs) Verify() error {
-	for _, mode := range comp.Modes {
-		switch mode {
-		case modeOff:
-			comp.filter.filter = true
-			comp.filter.showOff = true
-		case modeInitial:
-			comp.filter.filter = true
-			comp.filter.showInitial = true
-		case modeAuto:
-			comp.filter.filter = true
-			comp.filter.showAuto = true
+	if comp.Watch && (comp.Brief || comp.encoded()) {
+		return fmt.Errorf("--watch can only be used with column output")
+	}
+	if comp.Brief && comp.Format != nil {
+		return fmt.Errorf("--brief can't be combined with --output-format")
+	}
+	if comp.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if comp.Format != nil {
+		if err := verifyFormat(*comp.Format, formatWide, formatLimits, formatJSON, formatYAML, formatCSV, formatTSV, formatMarkdown); err != nil {
+			return err
 		}
 	}
+	comp.filter.modes = make(map[string]bool)
+	for _, mode := range comp.Modes {
+		comp.filter.filter = true
+		comp.filter.modes[mode.String()] = true
+	}
 	if comp.InvertFilter && !comp.filter.filter {
 		comp.filter.filter = true
-		comp.filter.showOff = true
-		comp.filter.showInitial = true
-		comp.filter.showAuto = true
+		for _, mode := range allModes {
+			comp.filter.modes[mode.String()] = true
+		}
 	}
 	return nil
 }
 
 func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
-	pods, err := k8.Pods(args.Namespace).List(context.TODO(), v1.ListOptions{})
+	if comp.Watch {
+		comp.watch(k8, args)
+		return
+	}
+
+	podList, err := comp.collect(k8, args)
 	if err != nil {
 		panic(err)
 	}
+	comp.output(podList, args)
+}
+
+// collect reads all running pods and VPAs and match them together
+func (comp *compareArgs) collect(k8 *k8client, args *cmdArgs) ([]podData, error) {
+	pods, err := k8.Pods(args.Namespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
 
 	result, err := k8.VPAs(args.Namespace)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	var usage map[string]resourceData
+	if comp.Usage {
+		usage, err = k8.PodUsage(args.Namespace)
+		if err != nil && !comp.noMetrics {
+			fmt.Fprintf(os.Stderr, "warning: metrics-server not available, no usage shown: %v\n", err)
+			comp.noMetrics = true
+		}
 	}
 	//fmt.Printf("There are %d vpas in the cluster\n", len(result.Items))
 
@@ -79,22 +118,26 @@ func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
 				api:        target.APIVersion,
 				kind:       strings.ToLower(target.Kind),
 				name:       target.Name,
+				mode:       updateModeOf(&v),
 				containers: make(map[string]*vpaContainerData),
 			}
-			if v.Spec.UpdatePolicy != nil && v.Spec.UpdatePolicy.UpdateMode != nil {
-				vpadata.mode = string(*v.Spec.UpdatePolicy.UpdateMode)
+			if v.Spec.ResourcePolicy != nil {
+				vpadata.controlled = make(map[string]vpa.ContainerControlledValues)
+				for _, policy := range v.Spec.ResourcePolicy.ContainerPolicies {
+					if policy.ControlledValues != nil {
+						vpadata.controlled[policy.ContainerName] = *policy.ControlledValues
+					}
+				}
 			}
 
 			if recommend != nil {
 				for _, values := range recommend.ContainerRecommendations {
-					var cont = &vpaContainerData{}
-					if v, ok := values.Target["cpu"]; ok {
-						cont.cpu = getCPU(&v)
+					vpadata.containers[values.ContainerName] = &vpaContainerData{
+						resourceData: getResources(values.Target),
+						lower:        getResources(values.LowerBound),
+						upper:        getResources(values.UpperBound),
+						uncapped:     getResources(values.UncappedTarget),
 					}
-					if v, ok := values.Target["memory"]; ok {
-						cont.memory = getMemory(&v)
-					}
-					vpadata.containers[values.ContainerName] = cont
 				}
 			}
 			vpas[vpadata.Key()] = vpadata
@@ -114,33 +157,27 @@ func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
 				namespace:  p.Namespace,
 				containers: make(map[string]*containerData),
 			}
-			for _, owner := range p.GetOwnerReferences() {
+			owner := k8.Owner(&p, "v1", kindPod)
+			if owner.Err != nil && !comp.unresolved[owner.Err.Error()] {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", owner.Err)
+				if comp.unresolved == nil {
+					comp.unresolved = make(map[string]bool)
+				}
+				comp.unresolved[owner.Err.Error()] = true
+			}
+			if owner.Kind != kindPod {
 				pod.ownerAPI = owner.APIVersion
 				pod.ownerKind = strings.ToLower(owner.Kind)
 				pod.ownerName = owner.Name
 			}
-			if pod.ownerKind == "job" {
-				pod.ownerKind = "cronjob"
-				if i := strings.LastIndex(pod.ownerName, "-"); i > 0 {
-					pod.ownerName = pod.ownerName[:i]
-				}
-			}
-			if pod.ownerKind == "replicaset" {
-				pod.ownerKind = "deployment"
-				if i := strings.LastIndex(pod.ownerName, "-"); i > 0 {
-					pod.ownerName = pod.ownerName[:i]
-				}
-			}
 			pod.vpa = vpas[pod.Key()]
-			for _, c := range p.Spec.Containers {
-				cont := &containerData{
-					cpu:    getCPU(c.Resources.Requests.Cpu()),
-					memory: getMemory(c.Resources.Requests.Memory()),
-				}
-				if pod.vpa != nil {
-					cont.vpa = pod.vpa.containers[c.Name]
-				}
-				pod.containers[c.Name] = cont
+			for i := range p.Spec.InitContainers {
+				c := &p.Spec.InitContainers[i]
+				pod.containers[c.Name] = newContainerData(&pod, c, containerType(c, true), usage)
+			}
+			for i := range p.Spec.Containers {
+				c := &p.Spec.Containers[i]
+				pod.containers[c.Name] = newContainerData(&pod, c, containerType(c, false), usage)
 			}
 			podList = append(podList, pod)
 			if args.Debug {
@@ -149,64 +186,61 @@ func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
 		}
 	}
 
-	var cw *columns.Writer
-	if !comp.Brief {
-		cw = columns.New(os.Stdout, "< < < < > > > > > > >")
-		cw.Headers("Namespace", "Name", "Mode", "Container", "Req-CPU", "VPA-CPU", "CPU diff%", "Req-RAM", "VPA-RAM", "Mem. diff%", "sum(Δ)")
-		cw.HeaderSeparator = true
-		if comp.Sum {
-			cw.Footer(5, columns.Sum(0))
-			cw.Footer(6, columns.Sum(0))
-			cw.Footer(8, columns.Sum(0))
-			cw.Footer(9, columns.Sum(0))
-		}
+	return podList, nil
+}
+
+func newContainerData(pod *podData, c *corev1.Container, ctype string, usage map[string]resourceData) *containerData {
+	cont := &containerData{
+		ctype: ctype,
+		resourceData: resourceData{
+			cpu:    getCPU(c.Resources.Requests.Cpu()),
+			memory: getMemory(c.Resources.Requests.Memory()),
+		},
+		limits: resourceData{
+			cpu:    getCPU(c.Resources.Limits.Cpu()),
+			memory: getMemory(c.Resources.Limits.Memory()),
+		},
+	}
+	if pod.vpa != nil {
+		cont.vpa = pod.vpa.containers[c.Name]
+		cont.controlled = pod.vpa.controlledValues(c.Name)
+	}
+	if u, ok := usage[usageKey(pod.namespace, pod.name, c.Name)]; ok {
+		cont.usage = &u
 	}
+	return cont
+}
 
-	diffStyle := columns.NewStyle().Suffix("%").ColorFunc(colorDiff)
+// output prints the matched pods in the selected format
+func (comp *compareArgs) output(podList []podData, args *cmdArgs) {
+
+	var cw *columns.Writer
+	var records = make([]*compareRecord, 0)
+	if !comp.Brief && !comp.encoded() {
+		cw = comp.newWriter()
+	}
 
 	var haveVPA bool
 	var printed map[string]bool
 	for _, pod := range podList {
 
 		for cname, c := range pod.containers {
-			var cols = make([]interface{}, 0, 11)
-			cols = append(cols, pod.namespace, pod.name)
 			if args.Debug {
 				fmt.Printf("adding pod %s/%s with container %s to output\n", pod.namespace, pod.name, cname)
 			}
 
 			haveVPA = pod.vpa != nil && c.vpa != nil
-
-			if pod.vpa != nil {
-				if c.vpa != nil {
-					diffCPU := (c.cpu - c.vpa.cpu) * 100 / c.vpa.cpu
-					diffMemory := (c.memory - c.vpa.memory) * 100 / c.vpa.memory
-					dCPU := columns.Cell(diffCPU).Style(diffStyle)
-					dMemory := columns.Cell(diffMemory).Style(diffStyle)
-
-					cols = append(cols, pod.vpa.mode, cname, c.cpu, c.vpa.cpu, dCPU, mem2mb(c.memory), mem2mb(c.vpa.memory), dMemory, diffCPU+diffMemory)
-					//fmt.Printf("    %s  %d/%d  %d/%d\n", cname, c.cpu, c.vpa.cpu, c.memory, c.vpa.memory)
-				} else {
-					cols = append(cols, pod.vpa.mode, cname, c.cpu, nil, nil, mem2mb(c.memory), nil, nil)
-					//fmt.Printf("    %s  %d/?  %d/?\n", cname, c.cpu, c.memory)
-				}
-			} else {
-				cols = append(cols, "---", cname, c.cpu, nil, nil, mem2mb(c.memory), nil, nil)
+			if comp.LimitRisk && c.limitRisk() == "" {
+				continue
+			}
+			cols := comp.row(&pod, cname, c)
+			if comp.Watch {
+				cols = comp.highlight(cols, &pod, cname, c)
 			}
 			if args.Compare.AllPods || haveVPA || args.Compare.InvertFilter {
 				show := false
 				if args.Compare.filter.filter {
-					if pod.vpa != nil {
-						if args.Compare.filter.showOff && pod.vpa.mode == modeOffText {
-							show = true
-						}
-						if args.Compare.filter.showInitial && pod.vpa.mode == modeInitialText {
-							show = true
-						}
-						if args.Compare.filter.showAuto && pod.vpa.mode == modeAutoText {
-							show = true
-						}
-					}
+					show = pod.vpa != nil && args.Compare.filter.modes[pod.vpa.mode]
 				} else {
 					show = true
 				}
@@ -228,15 +262,23 @@ func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
 							fmt.Println(brief)
 							printed[brief] = true
 						}
-					} else {
+					} else if cw != nil {
 						cw.Write(cols...)
+					} else {
+						records = append(records, newCompareRecord(&pod, cname, c))
 					}
 				}
 			}
 		}
 	}
 
-	if !comp.Brief {
+	if !comp.Brief && cw == nil {
+		if err := comp.writeRecords(records); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
+
+	if cw != nil {
 		if args.Compare.Head >= 0 {
 			cw.Head(args.Compare.Head)
 		}
@@ -252,6 +294,196 @@ func (comp *compareArgs) Exec(k8 *k8client, args *cmdArgs) {
 	}
 }
 
+func (comp *compareArgs) wide() bool {
+	return comp.Format != nil && *comp.Format == formatWide
+}
+
+// encoded is true when the output is records instead of columns
+func (comp *compareArgs) encoded() bool {
+	if comp.Format == nil {
+		return false
+	}
+	switch *comp.Format {
+	case formatJSON, formatYAML, formatCSV, formatTSV, formatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (comp *compareArgs) limits() bool {
+	return comp.Format != nil && *comp.Format == formatLimits
+}
+
+func (comp *compareArgs) newWriter() *columns.Writer {
+	var format string
+	var headers []string
+	var sums []int
+	switch {
+	case comp.limits():
+		format = "< < < < < < > > > > > > > > > > > > <"
+		headers = []string{"Namespace", "Name", "Mode", "Container", "Type", "Controlled",
+			"Req-CPU", "Lim-CPU", "VPA-CPU", "Up-CPU", "Lim/VPA", "Lim/Up",
+			"Req-RAM", "Lim-RAM", "VPA-RAM", "Up-RAM", "Lim/VPA", "Lim/Up",
+			"Risk"}
+		sums = []int{7, 8, 9, 10, 13, 14, 15, 16}
+	case comp.wide():
+		format = "< < < < < > > > > > > > > > > > > > <"
+		headers = []string{"Namespace", "Name", "Mode", "Container", "Type",
+			"Req-CPU", "Low-CPU", "VPA-CPU", "Up-CPU", "Uncap-CPU", "CPU diff%",
+			"Req-RAM", "Low-RAM", "VPA-RAM", "Up-RAM", "Uncap-RAM", "Mem. diff%",
+			"sum(Δ)", "Bounds"}
+		sums = []int{6, 7, 8, 9, 10, 12, 13, 14, 15, 16}
+	default:
+		format = "< < < < < > > > > > > >"
+		headers = []string{"Namespace", "Name", "Mode", "Container", "Type", "Req-CPU", "VPA-CPU", "CPU diff%", "Req-RAM", "VPA-RAM", "Mem. diff%", "sum(Δ)"}
+		sums = []int{6, 7, 9, 10}
+	}
+
+	if comp.Usage {
+		n := len(headers)
+		format += " > > > >"
+		headers = append(headers, "Use-CPU", "Use/Req", "Use-RAM", "Use/Req")
+		sums = append(sums, n+1, n+3)
+	}
+
+	cw := columns.New(os.Stdout, format)
+	cw.Headers(headers...)
+	cw.HeaderSeparator = true
+	if comp.Sum {
+		for _, i := range sums {
+			cw.Footer(i, columns.Sum(0))
+		}
+	}
+	return cw
+}
+
+var (
+	diffStyle   = columns.NewStyle().Suffix("%").ColorFunc(colorDiff)
+	boundsStyle = columns.NewStyle().Color(ansi.Red)
+)
+
+// row creates the output columns for a single container
+func (comp *compareArgs) row(pod *podData, cname string, c *containerData) []interface{} {
+	var cols = make([]interface{}, 0, 23)
+
+	mode := "---"
+	if pod.vpa != nil {
+		mode = pod.vpa.mode
+	}
+	cols = append(cols, pod.namespace, pod.name, mode, cname, c.ctype)
+
+	switch {
+	case comp.limits():
+		cols = append(cols, comp.limitColumns(c)...)
+
+	case c.vpa == nil && comp.wide():
+		cols = append(cols, c.cpu, nil, nil, nil, nil, nil, mem2mb(c.memory), nil, nil, nil, nil, nil, nil, nil)
+
+	case c.vpa == nil:
+		cols = append(cols, c.cpu, nil, nil, mem2mb(c.memory), nil, nil, nil)
+
+	default:
+		diffCPU := relativeDiff(c.cpu, c.vpa.cpu)
+		diffMemory := relativeDiff(c.memory, c.vpa.memory)
+		dCPU := columns.Cell(diffCPU).Style(diffStyle)
+		dMemory := columns.Cell(diffMemory).Style(diffStyle)
+
+		if comp.wide() {
+			cols = append(cols,
+				c.cpu, c.vpa.lower.cpu, c.vpa.cpu, c.vpa.upper.cpu, c.vpa.uncapped.cpu, dCPU,
+				mem2mb(c.memory), mem2mb(c.vpa.lower.memory), mem2mb(c.vpa.memory), mem2mb(c.vpa.upper.memory), mem2mb(c.vpa.uncapped.memory), dMemory,
+				diffCPU+diffMemory, columns.Cell(c.outOfBounds()).Style(boundsStyle))
+		} else {
+			cols = append(cols, c.cpu, c.vpa.cpu, dCPU, mem2mb(c.memory), mem2mb(c.vpa.memory), dMemory, diffCPU+diffMemory)
+		}
+	}
+
+	if comp.Usage {
+		if c.usage != nil {
+			cols = append(cols, c.usage.cpu, ratio(c.usage.cpu, c.cpu), mem2mb(c.usage.memory), ratio(c.usage.memory, c.memory))
+		} else {
+			cols = append(cols, nil, nil, nil, nil)
+		}
+	}
+	return cols
+}
+
+func (comp *compareArgs) limitColumns(c *containerData) []interface{} {
+	controlled := "---"
+	if c.controlled != "" {
+		controlled = string(c.controlled)
+	}
+	if c.vpa == nil {
+		return []interface{}{controlled,
+			c.cpu, c.limits.cpu, nil, nil, nil, nil,
+			mem2mb(c.memory), mem2mb(c.limits.memory), nil, nil, nil, nil,
+			nil}
+	}
+	return []interface{}{controlled,
+		c.cpu, c.limits.cpu, c.vpa.cpu, c.vpa.upper.cpu, ratio(c.limits.cpu, c.vpa.cpu), ratio(c.limits.cpu, c.vpa.upper.cpu),
+		mem2mb(c.memory), mem2mb(c.limits.memory), mem2mb(c.vpa.memory), mem2mb(c.vpa.upper.memory), ratio(c.limits.memory, c.vpa.memory), ratio(c.limits.memory, c.vpa.upper.memory),
+		columns.Cell(c.limitRisk()).Style(boundsStyle)}
+}
+
+// limitRisk flags containers whose limit will be below the upper bound of the VPA
+// once VPA raises the request
+//
+// With 'RequestsAndLimits' the limit is scaled proportionally to the request,
+// with 'RequestsOnly' the limit stays at its current value.
+func (c *containerData) limitRisk() string {
+	if c.vpa == nil {
+		return ""
+	}
+	var flags []string
+	if expectedLimit(c.controlled, c.cpu, c.limits.cpu, c.vpa.upper.cpu) < c.vpa.upper.cpu {
+		flags = append(flags, "cpu")
+	}
+	if expectedLimit(c.controlled, c.memory, c.limits.memory, c.vpa.upper.memory) < c.vpa.upper.memory {
+		flags = append(flags, "mem")
+	}
+	return strings.Join(flags, ",")
+}
+
+// expectedLimit calculates the limit when 'value' is applied as request,
+// containers without a limit returns math.MaxInt64
+func expectedLimit(controlled vpa.ContainerControlledValues, request, limit, value int64) int64 {
+	if limit == 0 {
+		return math.MaxInt64
+	}
+	if controlled == vpa.ContainerControlledValuesRequestsAndLimits && request > 0 {
+		return int64(float64(value) * float64(limit) / float64(request))
+	}
+	return limit
+}
+
+func ratio(a, b int64) *columns.CellData {
+	if a == 0 || b == 0 {
+		return nil
+	}
+	return columns.Cell(math.Round(float64(a)*100/float64(b)) / 100)
+}
+
+// outOfBounds flags requests that are outside the lower- and upper-bound of the VPA
+func (c *containerData) outOfBounds() string {
+	if c.vpa == nil {
+		return ""
+	}
+	var flags []string
+	if c.vpa.lower.cpu > 0 && c.cpu < c.vpa.lower.cpu {
+		flags = append(flags, "cpu<low")
+	}
+	if c.vpa.upper.cpu > 0 && c.cpu > c.vpa.upper.cpu {
+		flags = append(flags, "cpu>up")
+	}
+	if c.vpa.lower.memory > 0 && c.memory < c.vpa.lower.memory {
+		flags = append(flags, "mem<low")
+	}
+	if c.vpa.upper.memory > 0 && c.memory > c.vpa.upper.memory {
+		flags = append(flags, "mem>up")
+	}
+	return strings.Join(flags, ",")
+}
+
 func colorDiff(o interface{}) (ansi.Style, bool) {
 	switch v := o.(type) {
 	case float64:
@@ -271,14 +503,22 @@ type vpaData struct {
 	namespace  string
 	name       string
 	mode       string
+	controlled map[string]vpa.ContainerControlledValues
 	containers map[string]*vpaContainerData
 }
 
-type vpaContainerData struct {
+type resourceData struct {
 	cpu    int64
 	memory int64
 }
 
+type vpaContainerData struct {
+	resourceData // target
+	lower        resourceData
+	upper        resourceData
+	uncapped     resourceData
+}
+
 type podData struct {
 	name       string
 	namespace  string
@@ -290,9 +530,38 @@ type podData struct {
 }
 
 type containerData struct {
-	vpa    *vpaContainerData
-	cpu    int64
-	memory int64
+	ctype string // containerTypeMain, containerTypeInit or containerTypeSidecar
+	resourceData
+	limits     resourceData
+	usage      *resourceData
+	controlled vpa.ContainerControlledValues
+	vpa        *vpaContainerData
+}
+
+// container types
+const (
+	containerTypeMain    = "container"
+	containerTypeInit    = "init"
+	containerTypeSidecar = "sidecar" // native sidecar, an init-container with 'restartPolicy: Always'
+)
+
+func containerType(c *corev1.Container, init bool) string {
+	switch {
+	case !init:
+		return containerTypeMain
+	case c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways:
+		return containerTypeSidecar
+	default:
+		return containerTypeInit
+	}
+}
+
+// relativeDiff is the difference between the value and the target in percent of the target (0 without a target)
+func relativeDiff(value, target int64) int64 {
+	if target == 0 {
+		return 0
+	}
+	return (value - target) * 100 / target
 }
 
 func getCPU(v *resource.Quantity) int64 {
@@ -309,12 +578,48 @@ func getMemory(v *resource.Quantity) int64 {
 	return v.Value()
 }
 
+func getResources(list corev1.ResourceList) resourceData {
+	var data resourceData
+	if v, ok := list[corev1.ResourceCPU]; ok {
+		data.cpu = getCPU(&v)
+	}
+	if v, ok := list[corev1.ResourceMemory]; ok {
+		data.memory = getMemory(&v)
+	}
+	return data
+}
+
+// controlledValues returns the ContainerControlledValues for a container,
+// falling back on the default-policy ('*') and then 'RequestsAndLimits'
+func (v *vpaData) controlledValues(cname string) vpa.ContainerControlledValues {
+	if cv, ok := v.controlled[cname]; ok {
+		return cv
+	}
+	if cv, ok := v.controlled[vpa.DefaultContainerResourcePolicy]; ok {
+		return cv
+	}
+	return vpa.ContainerControlledValuesRequestsAndLimits
+}
+
+// Key of the target, only using the group of the apiVersion so any version of a kind matches
 func (v *vpaData) Key() string {
-	return fmt.Sprintf("%s:%s@%s/%s", v.api, v.kind, v.namespace, v.name)
+	return fmt.Sprintf("%s:%s@%s/%s", apiGroup(v.api), v.kind, v.namespace, v.name)
 }
 
+// Key of the owner, only using the group of the apiVersion so any version of a kind matches
 func (v *podData) Key() string {
-	return fmt.Sprintf("%s:%s@%s/%s", v.ownerAPI, v.ownerKind, v.namespace, v.ownerName)
+	return fmt.Sprintf("%s:%s@%s/%s", apiGroup(v.ownerAPI), v.ownerKind, v.namespace, v.ownerName)
+}
+
+func apiGroup(apiVersion string) string {
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		// Deployments, DaemonSets & ReplicaSets in the old 'extensions' group are served by 'apps'
+		if apiVersion[:i] == "extensions" {
+			return "apps"
+		}
+		return apiVersion[:i]
+	}
+	return ""
 }
 
 func mem2mb(v int64) *columns.CellData {
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// compareRecord is the machine-readable output of 'compare' (one per pod/container)
+//
+// CPU-values are in millicores and memory-values in bytes,
+// all VPA-values are null/empty when the container have no matching recommendation.
+type compareRecord struct {
+	Namespace        string   `json:"namespace" yaml:"namespace"`
+	Pod              string   `json:"pod" yaml:"pod"`
+	Container        string   `json:"container" yaml:"container"`
+	Type             string   `json:"type" yaml:"type"`                         // container, init or sidecar (native sidecar)
+	Mode             string   `json:"mode" yaml:"mode"`                         // UpdateMode of the VPA ("" when no VPA)
+	OwnerKind        string   `json:"ownerKind" yaml:"ownerKind"`               // Kind of the workload owning the pod
+	OwnerName        string   `json:"ownerName" yaml:"ownerName"`               // Name of the workload owning the pod
+	ControlledValues string   `json:"controlledValues" yaml:"controlledValues"` // RequestsAndLimits or RequestsOnly ("" when no VPA)
+	RequestCPU       int64    `json:"requestCpu" yaml:"requestCpu"`
+	RequestMemory    int64    `json:"requestMemory" yaml:"requestMemory"`
+	LimitCPU         int64    `json:"limitCpu" yaml:"limitCpu"`
+	LimitMemory      int64    `json:"limitMemory" yaml:"limitMemory"`
+	TargetCPU        *int64   `json:"targetCpu" yaml:"targetCpu"`
+	TargetMemory     *int64   `json:"targetMemory" yaml:"targetMemory"`
+	LowerBoundCPU    *int64   `json:"lowerBoundCpu" yaml:"lowerBoundCpu"`
+	LowerBoundMemory *int64   `json:"lowerBoundMemory" yaml:"lowerBoundMemory"`
+	UpperBoundCPU    *int64   `json:"upperBoundCpu" yaml:"upperBoundCpu"`
+	UpperBoundMemory *int64   `json:"upperBoundMemory" yaml:"upperBoundMemory"`
+	UncappedCPU      *int64   `json:"uncappedTargetCpu" yaml:"uncappedTargetCpu"`
+	UncappedMemory   *int64   `json:"uncappedTargetMemory" yaml:"uncappedTargetMemory"`
+	UsageCPU         *int64   `json:"usageCpu" yaml:"usageCpu"`       // only with --usage
+	UsageMemory      *int64   `json:"usageMemory" yaml:"usageMemory"` // only with --usage
+	CPUDiff          *float64 `json:"cpuDiff" yaml:"cpuDiff"`         // (request - target) in percent of target
+	MemoryDiff       *float64 `json:"memoryDiff" yaml:"memoryDiff"`   // (request - target) in percent of target
+	OutOfBounds      string   `json:"outOfBounds" yaml:"outOfBounds"`
+	LimitRisk        string   `json:"limitRisk" yaml:"limitRisk"`
+}
+
+// compareRecordHeaders are the headers for csv, tsv & markdown (same order as values())
+var compareRecordHeaders = []string{
+	"namespace", "pod", "container", "type", "mode", "ownerKind", "ownerName", "controlledValues",
+	"requestCpu", "requestMemory", "limitCpu", "limitMemory",
+	"targetCpu", "targetMemory", "lowerBoundCpu", "lowerBoundMemory",
+	"upperBoundCpu", "upperBoundMemory", "uncappedTargetCpu", "uncappedTargetMemory",
+	"usageCpu", "usageMemory", "cpuDiff", "memoryDiff", "outOfBounds", "limitRisk",
+}
+
+func newCompareRecord(pod *podData, cname string, c *containerData) *compareRecord {
+	rec := &compareRecord{
+		Namespace:     pod.namespace,
+		Pod:           pod.name,
+		Container:     cname,
+		Type:          c.ctype,
+		OwnerKind:     pod.ownerKind,
+		OwnerName:     pod.ownerName,
+		RequestCPU:    c.cpu,
+		RequestMemory: c.memory,
+		LimitCPU:      c.limits.cpu,
+		LimitMemory:   c.limits.memory,
+	}
+	if c.usage != nil {
+		rec.UsageCPU = &c.usage.cpu
+		rec.UsageMemory = &c.usage.memory
+	}
+	if pod.vpa != nil {
+		rec.Mode = pod.vpa.mode
+		rec.ControlledValues = string(c.controlled)
+	}
+	if c.vpa != nil {
+		rec.TargetCPU = &c.vpa.cpu
+		rec.TargetMemory = &c.vpa.memory
+		rec.LowerBoundCPU = &c.vpa.lower.cpu
+		rec.LowerBoundMemory = &c.vpa.lower.memory
+		rec.UpperBoundCPU = &c.vpa.upper.cpu
+		rec.UpperBoundMemory = &c.vpa.upper.memory
+		rec.UncappedCPU = &c.vpa.uncapped.cpu
+		rec.UncappedMemory = &c.vpa.uncapped.memory
+		rec.CPUDiff = diffPercent(c.cpu, c.vpa.cpu)
+		rec.MemoryDiff = diffPercent(c.memory, c.vpa.memory)
+		rec.OutOfBounds = c.outOfBounds()
+		rec.LimitRisk = c.limitRisk()
+	}
+	return rec
+}
+
+func diffPercent(value, target int64) *float64 {
+	if target == 0 {
+		return nil
+	}
+	diff := math.Round(float64(value-target)*10000/float64(target)) / 100
+	return &diff
+}
+
+func (rec *compareRecord) values() []string {
+	return []string{
+		rec.Namespace, rec.Pod, rec.Container, rec.Type, rec.Mode, rec.OwnerKind, rec.OwnerName, rec.ControlledValues,
+		strconv.FormatInt(rec.RequestCPU, 10), strconv.FormatInt(rec.RequestMemory, 10),
+		strconv.FormatInt(rec.LimitCPU, 10), strconv.FormatInt(rec.LimitMemory, 10),
+		intText(rec.TargetCPU), intText(rec.TargetMemory),
+		intText(rec.LowerBoundCPU), intText(rec.LowerBoundMemory),
+		intText(rec.UpperBoundCPU), intText(rec.UpperBoundMemory),
+		intText(rec.UncappedCPU), intText(rec.UncappedMemory),
+		intText(rec.UsageCPU), intText(rec.UsageMemory),
+		floatText(rec.CPUDiff), floatText(rec.MemoryDiff),
+		rec.OutOfBounds, rec.LimitRisk,
+	}
+}
+
+func intText(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func floatText(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// writeRecords prints the records (sorted by namespace, pod and container) in the selected format
+func (comp *compareArgs) writeRecords(records []*compareRecord) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+
+	switch *comp.Format {
+	case formatCSV, formatTSV:
+		w := csv.NewWriter(os.Stdout)
+		if *comp.Format == formatTSV {
+			w.Comma = '\t'
+		}
+		_ = w.Write(compareRecordHeaders)
+		for _, rec := range records {
+			_ = w.Write(rec.values())
+		}
+		w.Flush()
+		return w.Error()
+
+	case formatMarkdown:
+		fmt.Printf("| %s |\n", strings.Join(compareRecordHeaders, " | "))
+		fmt.Printf("|%s\n", strings.Repeat(" --- |", len(compareRecordHeaders)))
+		for _, rec := range records {
+			values := rec.values()
+			for i, v := range values {
+				values[i] = strings.ReplaceAll(v, "|", "\\|")
+			}
+			fmt.Printf("| %s |\n", strings.Join(values, " | "))
+		}
+		return nil
+
+	default:
+		enc, err := comp.Format.Encoder()
+		if err != nil {
+			return err
+		}
+		buf, err := enc.Encode(records)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(buf))
+		return nil
+	}
+}
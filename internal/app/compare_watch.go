@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/ninlil/ansi"
+	"github.com/ninlil/columns"
+)
+
+const (
+	clearScreen   = "\033[H\033[2J"
+	watchDebounce = time.Second
+)
+
+var changedStyle = columns.NewStyle().Color(ansi.NewStyle(ansi.Yellow, ansi.Bold))
+
+type watchFunc func(ctx context.Context) (watch.Interface, error)
+
+// watch redraws the output every time a pod or VPA changes,
+// or every 'Interval' if the watches are not allowed
+func (comp *compareArgs) watch(k8 *k8client, args *cmdArgs) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	events := make(chan struct{}, 1)
+	watching := comp.startWatch(ctx, events, func(ctx context.Context) (watch.Interface, error) {
+		return k8.Pods(args.Namespace).Watch(ctx, metav1.ListOptions{})
+	}) && comp.startWatch(ctx, events, func(ctx context.Context) (watch.Interface, error) {
+		return k8.WatchVPAs(ctx, args.Namespace)
+	})
+
+	var tick <-chan time.Time
+	if !watching {
+		ticker := time.NewTicker(comp.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		podList, err := comp.collect(k8, args)
+		fmt.Print(clearScreen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		} else {
+			comp.current = make(map[string]string)
+			comp.output(podList, args)
+			comp.previous = comp.current
+		}
+		if watching {
+			fmt.Printf("\nwatching pods and VPAs, updated %s\n", time.Now().Format(time.TimeOnly))
+		} else {
+			fmt.Printf("\nrefresh every %s, updated %s\n", comp.Interval, time.Now().Format(time.TimeOnly))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-events:
+			// wait for more events to settle before redrawing
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchDebounce):
+			}
+		}
+	}
+}
+
+// startWatch opens a watch and signals 'events' on every change,
+// false is returned if the watch could not be opened (i.e. not allowed)
+func (comp *compareArgs) startWatch(ctx context.Context, events chan<- struct{}, open watchFunc) bool {
+	w, err := open(ctx)
+	if err != nil {
+		return false
+	}
+
+	go func() {
+		for {
+			for range w.ResultChan() {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+			w.Stop()
+
+			// the server closes watches after a while, so re-open it until done
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				if w, err = open(ctx); err == nil {
+					break
+				}
+				time.Sleep(comp.Interval)
+			}
+		}
+	}()
+	return true
+}
+
+// highlight styles the text-columns of rows that changed since the previous output
+func (comp *compareArgs) highlight(cols []interface{}, pod *podData, cname string, c *containerData) []interface{} {
+	key := fmt.Sprintf("%s/%s/%s", pod.namespace, pod.name, cname)
+	mode := ""
+	if pod.vpa != nil {
+		mode = pod.vpa.mode
+	}
+	fingerprint := fmt.Sprintf("%s %v %v", mode, c.resourceData, c.limits)
+	if c.vpa != nil {
+		fingerprint += fmt.Sprintf(" %v", *c.vpa)
+	}
+	comp.current[key] = fingerprint
+
+	if comp.previous == nil || comp.previous[key] == fingerprint {
+		return cols
+	}
+	for i := 0; i < 4 && i < len(cols); i++ {
+		cols[i] = columns.Cell(cols[i]).Style(changedStyle)
+	}
+	return cols
+}
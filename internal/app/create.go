@@ -6,7 +6,12 @@ import (
 	"log"
 	"os"
 
-	"github.com/mickep76/encoding"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
 
 	// need these encodings
 	_ "github.com/mickep76/encoding/json"
@@ -19,13 +24,42 @@ type createArgs struct {
 	Mode      modeEnum   `arg:"-m,--mode" help:"Assign the VPA mode to the output"`
 	Filenames []string   `arg:"-f,--filename,separate" help:"Read names from input file (or '-' for stdin)"`
 	Format    formatEnum `arg:"-o,--output-format" help:"Select output format (yaml [default], json, toml)"`
+	Kind      string     `arg:"--kind" help:"Kind of the resource(s), for any kind with a pod-template (i.e. 'deploy' or 'rollout.argoproj.io')"`
+
+	Apply        bool       `arg:"--apply" help:"Apply the VPA(s) to the cluster instead of printing them"`
+	DryRun       dryRunEnum `arg:"--dry-run" help:"With --apply: none, client or server"`
+	Force        bool       `arg:"--force" help:"With --apply: overwrite existing VPAs for the same target"`
+	FieldManager string     `arg:"--field-manager" help:"With --apply: name of the field manager" default:"kubectl-vpa"`
+
+	MinCPU              string   `arg:"--min-cpu" help:"minAllowed cpu in the container-policies" default:"10m"`
+	MinMemory           string   `arg:"--min-memory" help:"minAllowed memory in the container-policies" default:"10Mi"`
+	MaxCPU              string   `arg:"--max-cpu" help:"maxAllowed cpu in the container-policies"`
+	MaxMemory           string   `arg:"--max-memory" help:"maxAllowed memory in the container-policies"`
+	ControlledResources []string `arg:"--controlled-resources,separate" help:"controlledResources in the container-policies (i.e. cpu,memory)"`
+	ControlledValues    string   `arg:"--controlled-values" help:"controlledValues in the container-policies: RequestsAndLimits or RequestsOnly"`
+	Containers          []string `arg:"--container,separate" help:"override the policy of a container (i.e. 'name:mode=Off,max-memory=1Gi')" placeholder:"NAME:KEY=VALUE"`
+	Policies            string   `arg:"--policies" help:"which container-policies to create: both ('*' and per container), default (only '*') or containers" default:"both"`
+	Sidecars            []string `arg:"--sidecar,separate" help:"name-pattern (or 'image:' + image-pattern) of sidecars to set to mode Off" placeholder:"PATTERN"`
+	NoDefaultSidecars   bool     `arg:"--no-default-sidecars" help:"don't use the built-in sidecar-patterns (istio-proxy, linkerd-proxy, vault-agent...)"`
+
+	policy    vpa.ContainerResourcePolicy
+	overrides map[string]*vpa.ContainerResourcePolicy
 }
 
 func (cr *createArgs) Verify() error {
 	if len(cr.Names) == 0 && len(cr.Filenames) == 0 {
 		return fmt.Errorf("no names specified")
 	}
-	return nil
+	if !cr.Apply && (cr.DryRun != dryRunNone || cr.Force) {
+		return fmt.Errorf("--dry-run and --force can only be used with --apply")
+	}
+	if cr.FieldManager == "" {
+		cr.FieldManager = defaultFieldManager
+	}
+	if err := cr.buildPolicies(); err != nil {
+		return err
+	}
+	return verifyFormat(cr.Format, formatYAML, formatJSON, formatTOML)
 }
 
 func (cr *createArgs) Exec(k8 *k8client, args *cmdArgs) {
@@ -34,12 +68,6 @@ func (cr *createArgs) Exec(k8 *k8client, args *cmdArgs) {
 		fmt.Printf("## format as %s\n", cr.Format.String())
 	}
 
-	yaml, err := cr.Format.Encoder()
-	if err != nil {
-		log.Printf("yaml-encoder-error: %v", err)
-		return
-	}
-
 	for _, filename := range cr.Filenames {
 		cr.Names = append(cr.Names, linesFromFile(filename)...)
 	}
@@ -48,12 +76,16 @@ func (cr *createArgs) Exec(k8 *k8client, args *cmdArgs) {
 		ns, name := args.getParts(input)
 
 		switch true {
-		case cr.createForPod(k8, ns, name, yaml, args):
-		case cr.createForDaemonSet(k8, ns, name, yaml, args):
-		case cr.createForStatefulSet(k8, ns, name, yaml, args):
-		case cr.createForDeployment(k8, ns, name, yaml, args):
-		case cr.createForCronJob(k8, ns, name, yaml, args):
-		//case cr.createForCronJobBeta(k8, ns, name, yaml, args):
+		case cr.Kind != "":
+			if !cr.createForKind(k8, ns, name, args) {
+				fmt.Fprintf(os.Stderr, "error: unable to locate %s %s/%s\n", cr.Kind, ns, name)
+			}
+		case cr.createForPod(k8, ns, name, args):
+		case cr.createForDaemonSet(k8, ns, name, args):
+		case cr.createForStatefulSet(k8, ns, name, args):
+		case cr.createForDeployment(k8, ns, name, args):
+		case cr.createForCronJob(k8, ns, name, args):
+		//case cr.createForCronJobBeta(k8, ns, name, args):
 		default:
 			fmt.Fprintf(os.Stderr, "error: unable to locate resource %s/%s\n", ns, name)
 		}
@@ -90,123 +122,62 @@ func linesFromFile(filename string) []string {
 	return lines
 }
 
-type vpaRoot struct {
-	APIVersion string  `yaml:"apiVersion"`
-	Kind       string  `yaml:"kind"`
-	Meta       vpaMeta `yaml:"metadata"`
-	Spec       vpaSpec `yaml:"spec"`
-}
-type vpaMeta struct {
-	Name      string `yaml:"name"`
-	Namespace string `yaml:"namespace"`
-}
-type vpaSpec struct {
-	RsrcPolicy   vpaRsrcPolicy   `yaml:"resourcePolicy"`
-	Target       vpaTarget       `yaml:"targetRef"`
-	UpdatePolicy vpaUpdatePolicy `yaml:"updatePolicy"`
-}
-type vpaRsrcPolicy struct {
-	Containers []vpaContPolicy `yaml:"containerPolicies"`
-}
-type vpaContPolicy struct {
-	Name     string   `yaml:"containerName"`
-	MinAllow vpaAllow `yaml:"minAllowed,omitempty"`
-	Mode     string   `yaml:"mode"`
-}
-type vpaAllow struct {
-	CPU    string `yaml:"cpu"`
-	Memory string `yaml:"memory"`
-}
-type vpaTarget struct {
-	APIVersion string `yaml:"apiVersion"`
-	Kind       string `yaml:"kind"`
-	Name       string `yaml:"name"`
-}
-type vpaUpdatePolicy struct {
-	UpdateMode string `yaml:"updateMode"`
-}
-
-func (cr *createArgs) createForDaemonSet(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForDaemonSet(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	ds, err := k8.DaemonSet(ns, name)
 	if err != nil {
 		return false
 	}
-	var cnames []string
-
-	for _, c := range ds.Spec.Template.Spec.Containers {
-		cnames = append(cnames, c.Name)
-	}
 
-	cr.createVPA(enc, "DaemonSet", ns, name, cnames, args)
+	cr.createVPA(k8, "apps/v1", kindDaemonSet, ns, name, &ds.Spec.Template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createForStatefulSet(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForStatefulSet(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	ss, err := k8.StatefulSet(ns, name)
 	if err != nil {
 		return false
 	}
-	var cnames []string
 
-	for _, c := range ss.Spec.Template.Spec.Containers {
-		cnames = append(cnames, c.Name)
-	}
-
-	cr.createVPA(enc, "StatefulSet", ns, name, cnames, args)
+	cr.createVPA(k8, "apps/v1", kindStatefulSet, ns, name, &ss.Spec.Template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createForDeployment(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForDeployment(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	dep, err := k8.Deployment(ns, name)
 	if err != nil {
 		return false
 	}
-	var cnames []string
-
-	for _, c := range dep.Spec.Template.Spec.Containers {
-		cnames = append(cnames, c.Name)
-	}
 
-	cr.createVPA(enc, "Deployment", ns, name, cnames, args)
+	cr.createVPA(k8, "apps/v1", kindDeployment, ns, name, &dep.Spec.Template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createForCronJob(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForCronJob(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	job, err := k8.CronJob(ns, name)
 	if err != nil {
 		return false
 	}
-	var cnames []string
-
-	for _, c := range job.Spec.JobTemplate.Spec.Template.Spec.Containers {
-		cnames = append(cnames, c.Name)
-	}
 
-	cr.createVPA(enc, "CronJob", ns, name, cnames, args)
+	cr.createVPA(k8, "batch/v1", kindCronJob, ns, name, &job.Spec.JobTemplate.Spec.Template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createForCronJobBeta(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForCronJobBeta(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	job, err := k8.CronJobBeta(ns, name)
 	if err != nil {
 		return false
 	}
-	var cnames []string
 
-	for _, c := range job.Spec.JobTemplate.Spec.Template.Spec.Containers {
-		cnames = append(cnames, c.Name)
-	}
-
-	cr.createVPA(enc, "CronJobBeta", ns, name, cnames, args)
+	cr.createVPA(k8, "batch/v1beta1", kindCronJob, ns, name, &job.Spec.JobTemplate.Spec.Template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createForPod(k8 *k8client, ns, name string, enc encoding.Codec, args *cmdArgs) bool {
+func (cr *createArgs) createForPod(k8 *k8client, ns, name string, args *cmdArgs) bool {
 
 	pod, err := k8.Pod(ns, name)
 	if err != nil {
@@ -217,52 +188,42 @@ func (cr *createArgs) createForPod(k8 *k8client, ns, name string, enc encoding.C
 		fmt.Printf("\n# create vpa-yaml for pod %s/%s\n", pod.Name, pod.Namespace)
 	}
 
-	kind := kindPod
-	ns = pod.Namespace
-	name = pod.Name
+	owner := k8.Owner(pod, "v1", kindPod)
+	if owner.Err != nil {
+		fmt.Fprintf(os.Stderr, "error: pod %s/%s: %v\n", pod.Namespace, pod.Name, owner.Err)
+		return true
+	}
+	if args.Debug {
+		fmt.Printf("# owner = %s\n", owner)
+	}
 
-	for i, owner := range pod.GetOwnerReferences() {
-		kind = owner.Kind
-		name = owner.Name
+	cr.createVPA(k8, owner.APIVersion, owner.Kind, pod.Namespace, owner.Name, &pod.Spec, args)
+	return true
+}
+
+// createForKind creates a VPA for any kind of workload, using the pod-template found through the template-paths
+func (cr *createArgs) createForKind(k8 *k8client, ns, name string, args *cmdArgs) bool {
+
+	obj, err := k8.Workload(ns, cr.Kind, name)
+	if err != nil {
 		if args.Debug {
-			fmt.Printf("# owner[%d] = %s '%s'\n", i, owner.Kind, owner.Name)
+			fmt.Printf("# %s %s/%s: %v\n", cr.Kind, ns, name, err)
 		}
+		return false
 	}
 
-	switch kind {
-	case kindReplicaSet:
-		kind = kindDeployment
-	case kindJob:
-		kind = kindCronJob
-	}
-
-	var cnames = make([]string, 0, len(pod.Spec.Containers))
-	for _, c := range pod.Spec.Containers {
-		cnames = append(cnames, c.Name)
+	template, err := podTemplate(obj)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s %s/%s: %v\n", obj.GetKind(), ns, name, err)
+		return true
 	}
 
-	cr.createVPA(enc, kind, ns, name, cnames, args)
+	cr.createVPA(k8, obj.GetAPIVersion(), obj.GetKind(), ns, name, &template.Spec, args)
 	return true
 }
 
-func (cr *createArgs) createVPA(enc encoding.Codec, kind, ns, name string, containers []string, args *cmdArgs) {
-	var version string
-
-	switch kind {
-	case kindReplicaSet, kindDeployment:
-		kind = kindDeployment
-		version = "apps/v1"
-	case kindJob:
-		kind = kindCronJob
-		version = "batch/v1"
-	case kindStatefulSet, kindDaemonSet:
-		version = "apps/v1"
-	case kindCronJob:
-		version = "batch/v1"
-	case kindCronJobBeta:
-		kind = kindCronJob
-		version = "batch/v1beta1"
-	default:
+func (cr *createArgs) createVPA(k8 *k8client, version, kind, ns, name string, spec *corev1.PodSpec, args *cmdArgs) {
+	if kind == kindPod {
 		log.Printf("Unsupported kind: %s", kind)
 		return
 	}
@@ -276,49 +237,61 @@ func (cr *createArgs) createVPA(enc encoding.Codec, kind, ns, name string, conta
 		fmt.Printf("# create for %s@%s %s/%s\n", kind, version, ns, name)
 	}
 
-	var vpa = vpaRoot{
-		APIVersion: "autoscaling.k8s.io/v1",
-		Kind:       kindVPA,
-		Meta: vpaMeta{
+	updateMode := vpa.UpdateMode(cr.Mode.String())
+	var obj = &vpa.VerticalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vpa.SchemeGroupVersion.String(),
+			Kind:       kindVPA,
+		},
+		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: ns,
 		},
-		Spec: vpaSpec{
-			RsrcPolicy: vpaRsrcPolicy{
-				Containers: []vpaContPolicy{
-					{
-						Name:     "*",
-						Mode:     "Auto",
-						MinAllow: vpaAllow{CPU: "10m", Memory: "10Mi"},
-					},
-				},
-			},
-			Target: vpaTarget{
+		Spec: vpa.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{
 				APIVersion: version,
 				Kind:       kind,
 				Name:       name,
 			},
-			UpdatePolicy: vpaUpdatePolicy{
-				UpdateMode: cr.Mode.String(),
+			UpdatePolicy: &vpa.PodUpdatePolicy{
+				UpdateMode: &updateMode,
+			},
+			ResourcePolicy: &vpa.PodResourcePolicy{
+				ContainerPolicies: cr.containerPolicies(cr.containersOf(spec)),
 			},
 		},
 	}
 
-	for _, cname := range containers {
-		vpa.Spec.RsrcPolicy.Containers = append(vpa.Spec.RsrcPolicy.Containers,
-			vpaContPolicy{
-				Name:     cname,
-				Mode:     "Auto",
-				MinAllow: vpaAllow{CPU: "10m", Memory: "10Mi"},
-			})
+	if cr.Apply {
+		cr.applyVPA(k8, obj)
+		return
 	}
 
-	buf, err := enc.Encode(vpa)
+	buf, err := encodeObject(cr.Format, obj)
 	if err != nil {
-		log.Printf("error encoding for %s %s/%s", kind, ns, name)
+		log.Printf("error encoding for %s %s/%s: %v", kind, ns, name, err)
+		return
+	}
+
+	if err := validateVPA(cr.Format, buf, obj); err != nil {
+		log.Printf("error validating output for %s %s/%s: %v", kind, ns, name, err)
 		return
 	}
 
 	fmt.Println("---")
 	fmt.Print(string(buf))
 }
+
+// validateVPA decodes the output back into a VPA and checks that it is the same as the original
+func validateVPA(f formatEnum, buf []byte, obj *vpa.VerticalPodAutoscaler) error {
+	var decoded vpa.VerticalPodAutoscaler
+	if err := decodeObject(f, buf, &decoded); err != nil {
+		return err
+	}
+	if decoded.APIVersion != obj.APIVersion || decoded.Kind != obj.Kind ||
+		decoded.Name != obj.Name || decoded.Namespace != obj.Namespace ||
+		!equality.Semantic.DeepEqual(decoded.Spec, obj.Spec) {
+		return fmt.Errorf("decoded output differs from the generated VPA")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+const defaultFieldManager = "kubectl-vpa"
+
+// applyVPA posts the VPA to the cluster using server-side apply and reports the result
+//
+// An existing VPA for the same target with the same spec is reported as unchanged,
+// one with another spec is never overwritten unless --force is used.
+func (cr *createArgs) applyVPA(k8 *k8client, v *vpa.VerticalPodAutoscaler) {
+	ns := v.Namespace
+	target := v.Spec.TargetRef
+	report := func(status string) {
+		fmt.Printf("verticalpodautoscaler %s/%s (%s %s/%s): %s\n", ns, v.Name, target.Kind, ns, target.Name, status)
+	}
+
+	existing, err := k8.VPAs(ns)
+	if err != nil {
+		report(fmt.Sprintf("error: %v", err))
+		return
+	}
+
+	// find the VPA for the same target and the VPA with the same name before changing the name
+	var sameTarget, sameName *vpa.VerticalPodAutoscaler
+	for i, item := range existing.Items {
+		ref := item.Spec.TargetRef
+		if sameTarget == nil && ref != nil && ref.Kind == target.Kind && ref.Name == target.Name &&
+			apiGroup(ref.APIVersion) == apiGroup(target.APIVersion) {
+			sameTarget = &existing.Items[i]
+		}
+		if item.Name == v.Name {
+			sameName = &existing.Items[i]
+		}
+	}
+
+	var current *vpa.VerticalPodAutoscaler
+	switch {
+	case sameTarget != nil && equality.Semantic.DeepEqual(sameTarget.Spec, v.Spec):
+		v.Name = sameTarget.Name
+		report("unchanged")
+		return
+	case sameTarget != nil && !cr.Force:
+		report(fmt.Sprintf("conflicting, already targeted by %s/%s (use --force to overwrite)", sameTarget.Namespace, sameTarget.Name))
+		return
+	case sameTarget != nil:
+		v.Name = sameTarget.Name
+		current = sameTarget
+	case sameName != nil && !cr.Force:
+		report("conflicting, a VPA with the same name has another target (use --force to overwrite)")
+		return
+	case sameName != nil:
+		current = sameName
+	}
+
+	if cr.DryRun == dryRunClient {
+		if current == nil {
+			report("created (dry run)")
+		} else {
+			report("configured (dry run)")
+		}
+		return
+	}
+
+	body, err := encodeObject(formatJSON, v)
+	if err != nil {
+		report(fmt.Sprintf("error: %v", err))
+		return
+	}
+
+	result, err := k8.ApplyVPA(ns, v.Name, body, cr.FieldManager, cr.Force, cr.DryRun)
+	var status string
+	switch {
+	case apierrors.IsConflict(err):
+		status = fmt.Sprintf("conflicting: %v", err)
+	case err != nil:
+		status = fmt.Sprintf("error: %v", err)
+	case current == nil:
+		status = "created"
+	case current.ResourceVersion == result.ResourceVersion || equality.Semantic.DeepEqual(current.Spec, result.Spec):
+		status = "unchanged"
+	default:
+		status = "configured"
+	}
+	if err == nil && cr.DryRun == dryRunServer {
+		status += " (server dry run)"
+	}
+	report(status)
+}
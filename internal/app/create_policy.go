@@ -0,0 +1,241 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// policy-layouts for --policies
+const (
+	policiesBoth       = "both"       // a default ('*') entry and one entry per container
+	policiesDefault    = "default"    // only a default ('*') entry
+	policiesContainers = "containers" // only one entry per container
+)
+
+// defaultSidecars are the name- and image-patterns of well-known injected sidecars
+var defaultSidecars = []string{
+	"istio-proxy",
+	"linkerd-proxy",
+	"vault-agent",
+	"consul-dataplane",
+	"image:*istio/proxyv2*",
+	"image:*linkerd*proxy*",
+	"image:*hashicorp/vault*",
+	"image:*consul-dataplane*",
+}
+
+// containerInfo is the info about a container needed to create its policy
+type containerInfo struct {
+	name    string
+	ctype   string // containerTypeMain, containerTypeInit or containerTypeSidecar
+	sidecar bool   // matches the sidecar-patterns
+}
+
+// off is true for containers that should have mode Off:
+// injected sidecars and one-shot init-containers (native sidecars are treated as any other container)
+func (c *containerInfo) off() bool {
+	return c.sidecar || c.ctype == containerTypeInit
+}
+
+// containersOf lists all containers (including init-containers) of a pod-spec
+func (cr *createArgs) containersOf(spec *corev1.PodSpec) []containerInfo {
+	var containers []containerInfo
+	for i := range spec.InitContainers {
+		c := &spec.InitContainers[i]
+		containers = append(containers, containerInfo{name: c.Name, ctype: containerType(c, true), sidecar: cr.isSidecar(c)})
+	}
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
+		containers = append(containers, containerInfo{name: c.Name, ctype: containerTypeMain, sidecar: cr.isSidecar(c)})
+	}
+	return containers
+}
+
+// isSidecar checks if the name or image of a container matches any of the sidecar-patterns
+func (cr *createArgs) isSidecar(c *corev1.Container) bool {
+	patterns := append([]string{}, cr.Sidecars...)
+	if !cr.NoDefaultSidecars {
+		patterns = append(patterns, defaultSidecars...)
+	}
+	for _, pattern := range patterns {
+		if image, ok := strings.CutPrefix(pattern, "image:"); ok {
+			if matchPattern(image, c.Image) {
+				return true
+			}
+		} else if matchPattern(pattern, c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a text with a pattern where '*' matches any text (including '/')
+func matchPattern(pattern, text string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == text
+	}
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(text, part)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(part):]
+	}
+	return strings.HasSuffix(text, parts[len(parts)-1])
+}
+
+// buildPolicies creates the base container-policy and the per-container overrides from the arguments
+func (cr *createArgs) buildPolicies() error {
+	switch strings.ToLower(cr.Policies) {
+	case policiesBoth, policiesDefault, policiesContainers:
+		cr.Policies = strings.ToLower(cr.Policies)
+	default:
+		return fmt.Errorf("unknown policies: '%s', allowed values: both, default & containers", cr.Policies)
+	}
+
+	mode := vpa.ContainerScalingModeAuto
+	cr.policy = vpa.ContainerResourcePolicy{Mode: &mode}
+
+	for key, value := range map[string]string{
+		"min-cpu":    cr.MinCPU,
+		"min-memory": cr.MinMemory,
+		"max-cpu":    cr.MaxCPU,
+		"max-memory": cr.MaxMemory,
+	} {
+		if value != "" {
+			if err := setPolicyValue(&cr.policy, key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if cr.ControlledValues != "" {
+		if err := setPolicyValue(&cr.policy, "controlled-values", cr.ControlledValues); err != nil {
+			return err
+		}
+	}
+
+	if len(cr.ControlledResources) > 0 {
+		var names []corev1.ResourceName
+		for _, list := range cr.ControlledResources {
+			for _, name := range strings.Split(list, ",") {
+				switch name = strings.ToLower(strings.TrimSpace(name)); name {
+				case "cpu", "memory":
+					names = append(names, corev1.ResourceName(name))
+				case "":
+				default:
+					return fmt.Errorf("unknown controlled resource: '%s', allowed values: cpu & memory", name)
+				}
+			}
+		}
+		cr.policy.ControlledResources = &names
+	}
+
+	cr.overrides = make(map[string]*vpa.ContainerResourcePolicy)
+	for _, text := range cr.Containers {
+		name, settings, ok := strings.Cut(text, ":")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid container override: '%s', expected NAME:KEY=VALUE[,KEY=VALUE...]", text)
+		}
+		policy, ok := cr.overrides[name]
+		if !ok {
+			policy = cr.policy.DeepCopy()
+			cr.overrides[name] = policy
+		}
+		for _, setting := range strings.Split(settings, ",") {
+			key, value, ok := strings.Cut(setting, "=")
+			if !ok {
+				return fmt.Errorf("invalid container override: '%s', expected KEY=VALUE", setting)
+			}
+			if err := setPolicyValue(policy, strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("container %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setPolicyValue sets a single value in the policy, used for both arguments and overrides
+func setPolicyValue(policy *vpa.ContainerResourcePolicy, key, value string) error {
+	switch key {
+	case "mode":
+		var mode vpa.ContainerScalingMode
+		switch strings.ToLower(value) {
+		case "auto":
+			mode = vpa.ContainerScalingModeAuto
+		case "off":
+			mode = vpa.ContainerScalingModeOff
+		default:
+			return fmt.Errorf("unknown container mode: '%s', allowed values: Auto & Off", value)
+		}
+		policy.Mode = &mode
+
+	case "controlled-values":
+		var cv vpa.ContainerControlledValues
+		switch strings.ToLower(value) {
+		case "requestsandlimits":
+			cv = vpa.ContainerControlledValuesRequestsAndLimits
+		case "requestsonly":
+			cv = vpa.ContainerControlledValuesRequestsOnly
+		default:
+			return fmt.Errorf("unknown controlled values: '%s', allowed values: RequestsAndLimits & RequestsOnly", value)
+		}
+		policy.ControlledValues = &cv
+
+	case "min-cpu", "min-memory", "max-cpu", "max-memory":
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		limit, rsrc, _ := strings.Cut(key, "-")
+		list := &policy.MinAllowed
+		if limit == "max" {
+			list = &policy.MaxAllowed
+		}
+		if *list == nil {
+			*list = make(corev1.ResourceList)
+		}
+		(*list)[corev1.ResourceName(rsrc)] = q
+
+	default:
+		return fmt.Errorf("unknown key: '%s', allowed keys: mode, controlled-values, min-cpu, min-memory, max-cpu & max-memory", key)
+	}
+	return nil
+}
+
+// containerPolicies creates the policies for the containers according to --policies and --container,
+// containers with mode Off and overridden containers always get their own policy
+func (cr *createArgs) containerPolicies(containers []containerInfo) []vpa.ContainerResourcePolicy {
+	var policies []vpa.ContainerResourcePolicy
+	if cr.Policies != policiesContainers {
+		policies = append(policies, cr.containerPolicy(containerInfo{name: vpa.DefaultContainerResourcePolicy}))
+	}
+	for _, c := range containers {
+		if _, ok := cr.overrides[c.name]; ok || c.off() || cr.Policies != policiesDefault {
+			policies = append(policies, cr.containerPolicy(c))
+		}
+	}
+	return policies
+}
+
+func (cr *createArgs) containerPolicy(c containerInfo) vpa.ContainerResourcePolicy {
+	policy := cr.policy.DeepCopy()
+	if override, ok := cr.overrides[c.name]; ok {
+		policy = override.DeepCopy()
+	} else if c.off() {
+		mode := vpa.ContainerScalingModeOff
+		policy.Mode = &mode
+	}
+	policy.ContainerName = c.name
+	return *policy
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ninlil/columns"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+type describeArgs struct {
+	Names []string `arg:"positional,required" help:"Name(s) of the VPA-resources to describe" placeholder:"NAME"`
+}
+
+func (desc *describeArgs) Verify() error {
+	if len(desc.Names) == 0 {
+		return fmt.Errorf("no names specified")
+	}
+	return nil
+}
+
+func (desc *describeArgs) Exec(k8 *k8client, args *cmdArgs) {
+	for i, input := range desc.Names {
+		ns, name := args.getParts(input)
+		if i > 0 {
+			fmt.Println()
+		}
+
+		v, err := k8.VPA(ns, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s/%s: %v\n", ns, name, err)
+			continue
+		}
+		describeVPA(k8, v)
+	}
+}
+
+// describeVPA shows the target, policies, recommendations and conditions of a VPA
+func describeVPA(k8 *k8client, v *vpa.VerticalPodAutoscaler) {
+	fmt.Printf("Name:         %s\n", v.Name)
+	fmt.Printf("Namespace:    %s\n", v.Namespace)
+	fmt.Printf("Update Mode:  %s\n", updateModeOf(v))
+	if v.Spec.UpdatePolicy != nil && v.Spec.UpdatePolicy.MinReplicas != nil {
+		fmt.Printf("Min Replicas: %d\n", *v.Spec.UpdatePolicy.MinReplicas)
+	}
+	if previous := previousModeOf(v); previous != "" {
+		fmt.Printf("Previous:     %s (changed by %s at %s)\n", previous, v.Annotations[annotationChangedBy], v.Annotations[annotationChangedAt])
+		if expiresAt := v.Annotations[annotationExpiresAt]; expiresAt != "" {
+			fmt.Printf("Expires:      %s\n", expiresAt)
+		}
+	}
+
+	pods := describeTarget(k8, v)
+	describePolicies(v)
+	describeRecommendations(v, pods)
+	describeConditions(v)
+}
+
+// describeTarget shows if the target exists and how many pods it has, and returns the pods
+func describeTarget(k8 *k8client, v *vpa.VerticalPodAutoscaler) []corev1.Pod {
+	target := v.Spec.TargetRef
+	if target == nil {
+		fmt.Printf("Target:       <none>\n")
+		return nil
+	}
+	fmt.Printf("Target:       %s/%s (%s)\n", target.Kind, target.Name, target.APIVersion)
+
+	if _, err := k8.ObjectMeta(v.Namespace, target.APIVersion, target.Kind, target.Name); err != nil {
+		fmt.Printf("  Exists:     no (%v)\n", err)
+	} else {
+		fmt.Printf("  Exists:     yes\n")
+	}
+
+	list, err := k8.Pods(v.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("  Pods:       unknown (%v)\n", err)
+		return nil
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		owner := k8.Owner(&pod, "v1", kindPod)
+		if owner.Kind == target.Kind && owner.Name == target.Name && apiGroup(owner.APIVersion) == apiGroup(target.APIVersion) {
+			pods = append(pods, pod)
+		}
+	}
+	fmt.Printf("  Pods:       %d\n", len(pods))
+	return pods
+}
+
+func describePolicies(v *vpa.VerticalPodAutoscaler) {
+	fmt.Println("Resource Policies:")
+	if v.Spec.ResourcePolicy == nil || len(v.Spec.ResourcePolicy.ContainerPolicies) == 0 {
+		fmt.Println("  <none>")
+		return
+	}
+
+	cw := columns.New(os.Stdout, "< < < < < <")
+	cw.Headers("Container", "Mode", "Controlled", "Values", "Min", "Max")
+	cw.HeaderSeparator = true
+	for _, policy := range v.Spec.ResourcePolicy.ContainerPolicies {
+		mode := string(vpa.ContainerScalingModeAuto)
+		if policy.Mode != nil {
+			mode = string(*policy.Mode)
+		}
+		controlled := "cpu,memory"
+		if policy.ControlledResources != nil {
+			var names []string
+			for _, name := range *policy.ControlledResources {
+				names = append(names, string(name))
+			}
+			controlled = strings.Join(names, ",")
+		}
+		values := string(vpa.ContainerControlledValuesRequestsAndLimits)
+		if policy.ControlledValues != nil {
+			values = string(*policy.ControlledValues)
+		}
+		cw.Write(policy.ContainerName, mode, controlled, values, resourceListText(policy.MinAllowed), resourceListText(policy.MaxAllowed))
+	}
+	cw.Flush()
+}
+
+// describeRecommendations shows the recommendation of each container next to the requests of the pods
+func describeRecommendations(v *vpa.VerticalPodAutoscaler, pods []corev1.Pod) {
+	fmt.Println("Recommendations:")
+	if v.Status.Recommendation == nil || len(v.Status.Recommendation.ContainerRecommendations) == 0 {
+		fmt.Println("  <none>")
+		return
+	}
+
+	cw := columns.New(os.Stdout, "< < > > > > >")
+	cw.Headers("Container", "Resource", "Requests", "Lower", "Target", "Upper", "Uncapped")
+	cw.HeaderSeparator = true
+	for _, rec := range v.Status.Recommendation.ContainerRecommendations {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			cw.Write(rec.ContainerName, string(name),
+				podRequests(pods, rec.ContainerName, name),
+				quantityText(rec.LowerBound, name),
+				quantityText(rec.Target, name),
+				quantityText(rec.UpperBound, name),
+				quantityText(rec.UncappedTarget, name))
+		}
+	}
+	cw.Flush()
+}
+
+func describeConditions(v *vpa.VerticalPodAutoscaler) {
+	fmt.Println("Conditions:")
+	if len(v.Status.Conditions) == 0 {
+		fmt.Println("  <none>")
+		return
+	}
+
+	cw := columns.New(os.Stdout, "< < < < <")
+	cw.Headers("Type", "Status", "Reason", "Last Transition", "Message")
+	cw.HeaderSeparator = true
+	for _, cond := range v.Status.Conditions {
+		transition := "---"
+		if !cond.LastTransitionTime.IsZero() {
+			transition = fmt.Sprintf("%s (%s ago)", cond.LastTransitionTime.UTC().Format(time.RFC3339),
+				time.Since(cond.LastTransitionTime.Time).Round(time.Second))
+		}
+		cw.Write(string(cond.Type), string(cond.Status), cond.Reason, transition, cond.Message)
+	}
+	cw.Flush()
+}
+
+// podRequests lists the distinct requests of a container in the pods
+func podRequests(pods []corev1.Pod, container string, name corev1.ResourceName) string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, pod := range pods {
+		for _, c := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			if c.Name != container {
+				continue
+			}
+			value := quantityText(c.Resources.Requests, name)
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return "---"
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
+
+func quantityText(list corev1.ResourceList, name corev1.ResourceName) string {
+	if q, ok := list[name]; ok {
+		return q.String()
+	}
+	return "---"
+}
+
+func resourceListText(list corev1.ResourceList) string {
+	if len(list) == 0 {
+		return "---"
+	}
+	var parts []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if q, ok := list[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, q.String()))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// mergePatch creates a JSON merge-patch (RFC 7386) that changes 'before' into 'after'
+//
+// The patch includes the resourceVersion of 'before', so it fails if the object was changed since it was read.
+func mergePatch(before, after interface{}) ([]byte, error) {
+	b, err := runtime.DefaultUnstructuredConverter.ToUnstructured(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := runtime.DefaultUnstructuredConverter.ToUnstructured(after)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := mergePatchOf(b, a)
+	if rv, found, _ := unstructured.NestedString(b, "metadata", "resourceVersion"); found && rv != "" {
+		if err := unstructured.SetNestedField(patch, rv, "metadata", "resourceVersion"); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(patch)
+}
+
+// mergePatchOf compares two objects, removed fields are set to nil and lists are replaced as a whole
+//
+// A removed map is never set to nil as a whole, only each of its fields (i.e. the annotations that are removed),
+// so fields added by others are kept.
+func mergePatchOf(before, after map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for key, value := range after {
+		old, ok := before[key]
+		switch {
+		case !ok:
+			patch[key] = value
+		case reflect.DeepEqual(old, value):
+		default:
+			oldMap, oldIsMap := old.(map[string]interface{})
+			newMap, newIsMap := value.(map[string]interface{})
+			if oldIsMap && newIsMap {
+				patch[key] = mergePatchOf(oldMap, newMap)
+			} else {
+				patch[key] = value
+			}
+		}
+	}
+	for key, old := range before {
+		if _, ok := after[key]; !ok {
+			if oldMap, isMap := old.(map[string]interface{}); isMap {
+				patch[key] = mergePatchOf(oldMap, map[string]interface{}{})
+			} else {
+				patch[key] = nil
+			}
+		}
+	}
+	return patch
+}
+
+// diffYAML shows the differences between two objects as yaml, with '-' and '+' in front of removed and added lines
+func diffYAML(before, after interface{}) (string, error) {
+	b, err := yaml.Marshal(before)
+	if err != nil {
+		return "", err
+	}
+	a, err := yaml.Marshal(after)
+	if err != nil {
+		return "", err
+	}
+	return diffLines(strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"),
+		strings.Split(strings.TrimSuffix(string(a), "\n"), "\n")), nil
+}
+
+// diffLines makes a line-diff using the longest common subsequence
+func diffLines(before, after []string) string {
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			sb.WriteString("  " + before[i] + "\n")
+			i++
+			j++
+		case i < len(before) && (j == len(after) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + before[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + after[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
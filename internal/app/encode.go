@@ -1,9 +1,15 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8json "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+
 	"github.com/mickep76/encoding"
 
 	// need these encodings
@@ -18,6 +24,11 @@ const (
 	formatYAML formatEnum = iota
 	formatJSON
 	formatTOML
+	formatWide
+	formatLimits
+	formatCSV
+	formatTSV
+	formatMarkdown
 )
 
 func (f *formatEnum) UnmarshalText(b []byte) error {
@@ -29,8 +40,18 @@ func (f *formatEnum) UnmarshalText(b []byte) error {
 		*f = formatJSON
 	case "toml":
 		*f = formatTOML
+	case "wide":
+		*f = formatWide
+	case "limits":
+		*f = formatLimits
+	case "csv":
+		*f = formatCSV
+	case "tsv":
+		*f = formatTSV
+	case "markdown", "md":
+		*f = formatMarkdown
 	default:
-		return fmt.Errorf("unknown mode: '%s', allowed values: yaml, json & toml", s)
+		return fmt.Errorf("unknown mode: '%s', allowed values: yaml, json, toml, wide, limits, csv, tsv & markdown", s)
 	}
 	return nil
 }
@@ -43,6 +64,16 @@ func (f formatEnum) String() string {
 		return "json"
 	case formatTOML:
 		return "toml"
+	case formatWide:
+		return "wide"
+	case formatLimits:
+		return "limits"
+	case formatCSV:
+		return "csv"
+	case formatTSV:
+		return "tsv"
+	case formatMarkdown:
+		return "markdown"
 	}
 	return "yaml"
 }
@@ -57,3 +88,82 @@ func (f formatEnum) Encoder() (encoding.Codec, error) {
 		return encoding.NewCodec(f.String())
 	}
 }
+
+// Serializer returns the Kubernetes-serializer for json and yaml (decoding is strict)
+func (f formatEnum) Serializer() (*k8json.Serializer, error) {
+	switch f {
+	case formatJSON, formatYAML:
+		return k8json.NewSerializerWithOptions(k8json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme,
+			k8json.SerializerOptions{Yaml: f == formatYAML, Pretty: true, Strict: true}), nil
+	}
+	return nil, fmt.Errorf("no kubernetes-serializer for %s", f)
+}
+
+// encodeObject serializes a Kubernetes-object, leaving out the status and creationTimestamp
+//
+// json & yaml uses the Kubernetes-serializers, toml uses the same field-names
+func encodeObject(f formatEnum, obj runtime.Object) ([]byte, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(u, "status")
+	unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+
+	if f == formatTOML {
+		enc, err := f.Encoder()
+		if err != nil {
+			return nil, err
+		}
+		return enc.Encode(u)
+	}
+
+	serializer, err := f.Serializer()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := serializer.Encode(&unstructured.Unstructured{Object: u}, &buf); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeObject decodes the output of encodeObject into 'into', failing on unknown fields
+func decodeObject(f formatEnum, buf []byte, into runtime.Object) error {
+	if f == formatTOML {
+		enc, err := f.Encoder()
+		if err != nil {
+			return err
+		}
+		var u map[string]interface{}
+		if err := enc.Decode(buf, &u); err != nil {
+			return err
+		}
+		return runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(u, into, true)
+	}
+
+	serializer, err := f.Serializer()
+	if err != nil {
+		return err
+	}
+	_, _, err = serializer.Decode(buf, nil, into)
+	return err
+}
+
+// verifyFormat checks that the format is one of the allowed ones for a subcommand
+func verifyFormat(f formatEnum, allowed ...formatEnum) error {
+	for _, a := range allowed {
+		if f == a {
+			return nil
+		}
+	}
+	names := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		names = append(names, a.String())
+	}
+	return fmt.Errorf("output-format '%s' not supported, allowed values: %s", f, strings.Join(names, ", "))
+}
@@ -3,20 +3,32 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
 	// v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 
 	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
@@ -29,22 +41,32 @@ const (
 	kindDeployment  = "Deployment"
 	kindJob         = "Job"
 	kindCronJob     = "CronJob"
-	kindCronJobBeta = "CronJobBeta"
 	kindPod         = "Pod"
 	kindStatefulSet = "StatefulSet"
 	kindDaemonSet   = "DaemonSet"
 )
 
 type k8client struct {
-	k8Client  *kubernetes.Clientset
-	vpaClient *rest.RESTClient
+	k8Client   *kubernetes.Clientset
+	vpaClient  *rest.RESTClient
+	metaClient metadata.Interface
+	dynClient  dynamic.Interface
+	mapper     meta.RESTMapper
+	discovery  discovery.CachedDiscoveryInterface
+	owners     map[string]*ownerData
+	ownerLists map[string]map[string]metav1.Object
+	username   string
 }
 
-// patchStringValue specifies a patch operation for a string.
-type patchStringValue struct {
-	Op    string `json:"op"`
-	Path  string `json:"path"`
-	Value string `json:"value"`
+// podMetricsList is the subset of metrics.k8s.io/v1beta1 PodMetricsList that we use
+type podMetricsList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Containers        []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
 }
 
 // Connect to k8s
@@ -76,9 +98,25 @@ func Connect(args *cmdArgs) (*k8client, error) {
 		return nil, err
 	}
 
+	metaClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dynClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedDiscovery := memory.NewMemCacheClient(clientset.Discovery())
+
 	return &k8client{
-		k8Client:  clientset,
-		vpaClient: exampleRestClient,
+		k8Client:   clientset,
+		vpaClient:  exampleRestClient,
+		metaClient: metaClient,
+		dynClient:  dynClient,
+		discovery:  cachedDiscovery,
+		mapper:     restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery), cachedDiscovery),
 	}, nil
 }
 
@@ -110,36 +148,181 @@ func (k8 *k8client) CronJobBeta(ns, name string) (*batchv1beta1.CronJob, error)
 	return k8.k8Client.BatchV1beta1().CronJobs(ns).Get(context.Background(), name, metav1.GetOptions{})
 }
 
+// Workload reads any kind of object, where 'kind' is in the same format as for kubectl (i.e. 'deploy', 'rollout.argoproj.io' or 'Rollout')
+func (k8 *k8client) Workload(ns, kind, name string) (*unstructured.Unstructured, error) {
+	gvr, err := k8.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return k8.dynClient.Resource(gvr).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// Object reads any kind of object, i.e. the target of a VPA
+func (k8 *k8client) Object(ns, apiVersion, kind, name string) (*unstructured.Unstructured, error) {
+	mapping, err := k8.restMapping(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return k8.dynClient.Resource(mapping.Resource).Get(context.Background(), name, metav1.GetOptions{})
+	}
+	return k8.dynClient.Resource(mapping.Resource).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// PatchObject changes any kind of object with a JSON-patch
+func (k8 *k8client) PatchObject(obj *unstructured.Unstructured, patch []byte, dryRun dryRunEnum) (*unstructured.Unstructured, error) {
+	mapping, err := k8.restMapping(obj.GetAPIVersion(), obj.GetKind())
+	if err != nil {
+		return nil, err
+	}
+	var opts metav1.PatchOptions
+	if dryRun == dryRunServer {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return k8.dynClient.Resource(mapping.Resource).Patch(context.Background(), obj.GetName(), types.JSONPatchType, patch, opts)
+	}
+	return k8.dynClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Patch(context.Background(), obj.GetName(), types.JSONPatchType, patch, opts)
+}
+
+func (k8 *k8client) restMapping(apiVersion, kind string) (*meta.RESTMapping, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	return k8.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: kind}, gv.Version)
+}
+
+// KindFor resolves a kind as given on the command-line (i.e. 'sts', 'deployments' or 'rollout.argoproj.io')
+func (k8 *k8client) KindFor(kind string) (schema.GroupKind, error) {
+	gvr, err := k8.resourceFor(kind)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	gvk, err := k8.mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	return gvk.GroupKind(), nil
+}
+
+func (k8 *k8client) resourceFor(kind string) (schema.GroupVersionResource, error) {
+	var gvr schema.GroupVersionResource
+	var err error
+	fullySpecified, gr := schema.ParseResourceArg(strings.ToLower(kind))
+	if fullySpecified != nil {
+		gvr, err = k8.mapper.ResourceFor(*fullySpecified)
+	}
+	if fullySpecified == nil || err != nil {
+		gvr, err = k8.mapper.ResourceFor(gr.WithVersion(""))
+	}
+	return gvr, err
+}
+
+// Username returns the name of the user as seen by the cluster,
+// falling back to the local username if the cluster doesn't support SelfSubjectReview
+func (k8 *k8client) Username() string {
+	if k8.username != "" {
+		return k8.username
+	}
+	review, err := k8.k8Client.AuthenticationV1().SelfSubjectReviews().Create(context.Background(), &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	switch {
+	case err == nil && review.Status.UserInfo.Username != "":
+		k8.username = review.Status.UserInfo.Username
+	case os.Getenv("USER") != "":
+		k8.username = os.Getenv("USER")
+	default:
+		k8.username = "unknown"
+	}
+	return k8.username
+}
+
 func (k8 *k8client) VPAs(ns string) (*vpa.VerticalPodAutoscalerList, error) {
+	return k8.SelectVPAs(ns, "")
+}
+
+// SelectVPAs lists the VPAs matching a label-selector
+func (k8 *k8client) SelectVPAs(ns, selector string) (*vpa.VerticalPodAutoscalerList, error) {
 	result := vpa.VerticalPodAutoscalerList{}
 	var req = k8.vpaClient.Get().Resource(vpaCRD)
 	if ns != "" {
 		req = req.Namespace(ns)
 	}
+	if selector != "" {
+		req = req.Param("labelSelector", selector)
+	}
 	err := req.Do(context.Background()).Into(&result)
 	return &result, err
 }
 
+// PodUsage reads the current usage of all containers from the metrics-server,
+// keyed by usageKey()
+func (k8 *k8client) PodUsage(ns string) (map[string]resourceData, error) {
+	path := "/apis/metrics.k8s.io/v1beta1/pods"
+	if ns != "" {
+		path = "/apis/metrics.k8s.io/v1beta1/namespaces/" + ns + "/pods"
+	}
+	buf, err := k8.k8Client.RESTClient().Get().AbsPath(path).DoRaw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var list podMetricsList
+	if err := json.Unmarshal(buf, &list); err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]resourceData)
+	for _, pod := range list.Items {
+		for _, c := range pod.Containers {
+			usage[usageKey(pod.Namespace, pod.Name, c.Name)] = getResources(c.Usage)
+		}
+	}
+	return usage, nil
+}
+
+func usageKey(ns, pod, container string) string {
+	return fmt.Sprintf("%s/%s/%s", ns, pod, container)
+}
+
+// WatchVPAs starts a watch on all VPAs in the namespace (or all namespaces if empty)
+func (k8 *k8client) WatchVPAs(ctx context.Context, ns string) (watch.Interface, error) {
+	var req = k8.vpaClient.Get().Resource(vpaCRD).Param("watch", "true")
+	if ns != "" {
+		req = req.Namespace(ns)
+	}
+	return req.Watch(ctx)
+}
+
 func (k8 *k8client) VPA(ns, name string) (*vpa.VerticalPodAutoscaler, error) {
 	result := vpa.VerticalPodAutoscaler{}
 	err := k8.vpaClient.Get().Resource(vpaCRD).Namespace(ns).Name(name).Do(context.Background()).Into(&result)
 	return &result, err
 }
 
-// PatchVPA
-//
-// Adapted from example: https://gist.github.com/dwmkerr/447692c8bba28929ef914239781c4e59
-func (k8 *k8client) PatchVPA(ns, name, path, value string) error {
-	payload := []patchStringValue{{
-		Op:    "replace",
-		Path:  path,
-		Value: value,
-	}}
-	payloadBytes, _ := json.Marshal(payload)
-	result := k8.vpaClient.Patch(types.JSONPatchType).Resource(vpaCRD).Namespace(ns).Name(name).Body(payloadBytes).Do(context.Background())
-	if err := result.Error(); err != nil {
-		return err
+// ApplyVPA creates or updates a VPA using server-side apply, 'body' is the VPA as yaml or json
+func (k8 *k8client) ApplyVPA(ns, name string, body []byte, fieldManager string, force bool, dryRun dryRunEnum) (*vpa.VerticalPodAutoscaler, error) {
+	result := vpa.VerticalPodAutoscaler{}
+	req := k8.vpaClient.Patch(types.ApplyPatchType).Resource(vpaCRD).Namespace(ns).Name(name).
+		Param("fieldManager", fieldManager).Body(body)
+	if force {
+		req = req.Param("force", "true")
+	}
+	if dryRun == dryRunServer {
+		req = req.Param("dryRun", metav1.DryRunAll)
 	}
+	err := req.Do(context.Background()).Into(&result)
+	return &result, err
+}
 
-	return nil
+// PatchVPA changes a VPA with a JSON merge-patch (see mergePatch), so missing parents (like updatePolicy) are created
+func (k8 *k8client) PatchVPA(ns, name string, patch []byte, dryRun dryRunEnum) (*vpa.VerticalPodAutoscaler, error) {
+	result := vpa.VerticalPodAutoscaler{}
+	req := k8.vpaClient.Patch(types.MergePatchType).Resource(vpaCRD).Namespace(ns).Name(name).Body(patch)
+	if dryRun == dryRunServer {
+		req = req.Param("dryRun", metav1.DryRunAll)
+	}
+	err := req.Do(context.Background()).Into(&result)
+	return &result, err
 }
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ninlil/columns"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+type listArgs struct {
+	Modes      []modeEnum  `arg:"-m,--mode,separate" help:"filter only VPAs with specified mode(s)" placeholder:"MODE"`
+	Conditions []string    `arg:"-c,--condition,separate" help:"filter only VPAs where the condition is True (i.e. LowConfidence), or not True with a '!' prefix" placeholder:"CONDITION"`
+	Format     *formatEnum `arg:"-o,--output-format" help:"Select output format (json, yaml)"`
+}
+
+// listConditions are the conditions shown as columns, a 'True' for any of them needs attention
+var listConditions = []vpa.VerticalPodAutoscalerConditionType{
+	vpa.LowConfidence,
+	vpa.NoPodsMatched,
+	vpa.ConfigUnsupported,
+}
+
+// listRecord is the machine-readable output of 'list' (one per VPA)
+type listRecord struct {
+	Namespace        string          `json:"namespace" yaml:"namespace"`
+	Name             string          `json:"name" yaml:"name"`
+	TargetAPIVersion string          `json:"targetApiVersion" yaml:"targetApiVersion"`
+	TargetKind       string          `json:"targetKind" yaml:"targetKind"`
+	TargetName       string          `json:"targetName" yaml:"targetName"`
+	Mode             string          `json:"mode" yaml:"mode"`
+	Recommendation   bool            `json:"recommendation" yaml:"recommendation"` // the VPA has a recommendation for at least one container
+	Conditions       []listCondition `json:"conditions" yaml:"conditions"`
+}
+
+type listCondition struct {
+	Type               string `json:"type" yaml:"type"`
+	Status             string `json:"status" yaml:"status"`
+	Reason             string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message            string `json:"message,omitempty" yaml:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty" yaml:"lastTransitionTime,omitempty"`
+}
+
+func (list *listArgs) Verify() error {
+	for _, cond := range list.Conditions {
+		if strings.TrimPrefix(cond, "!") == "" {
+			return fmt.Errorf("empty condition")
+		}
+	}
+	if list.Format != nil {
+		return verifyFormat(*list.Format, formatJSON, formatYAML)
+	}
+	return nil
+}
+
+func (list *listArgs) Exec(k8 *k8client, args *cmdArgs) {
+	result, err := k8.VPAs(args.Namespace)
+	if err != nil {
+		panic(err)
+	}
+
+	var records = make([]*listRecord, 0)
+	for i := range result.Items {
+		rec := newListRecord(&result.Items[i])
+		if list.show(rec) {
+			records = append(records, rec)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	if list.Format != nil {
+		enc, err := list.Format.Encoder()
+		if err != nil {
+			panic(err)
+		}
+		buf, err := enc.Encode(records)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Print(string(buf))
+		return
+	}
+
+	headers := []string{"Namespace", "Name", "Target", "Mode", "Recommendation"}
+	for _, cond := range listConditions {
+		headers = append(headers, string(cond))
+	}
+	cw := columns.New(os.Stdout, "< < < < <"+strings.Repeat(" <", len(listConditions)))
+	cw.Headers(headers...)
+	cw.HeaderSeparator = true
+	for _, rec := range records {
+		target := "---"
+		if rec.TargetKind != "" {
+			target = fmt.Sprintf("%s/%s", strings.ToLower(rec.TargetKind), rec.TargetName)
+		}
+		recommendation := columns.Cell("yes")
+		if !rec.Recommendation {
+			recommendation = columns.Cell("no").Style(boundsStyle)
+		}
+		cols := []interface{}{rec.Namespace, rec.Name, target, rec.Mode, recommendation}
+		for _, cond := range listConditions {
+			status := rec.status(string(cond))
+			if status == "True" {
+				cols = append(cols, columns.Cell(status).Style(boundsStyle))
+			} else {
+				cols = append(cols, status)
+			}
+		}
+		cw.Write(cols...)
+	}
+	cw.Flush()
+}
+
+func newListRecord(v *vpa.VerticalPodAutoscaler) *listRecord {
+	rec := &listRecord{
+		Namespace:  v.Namespace,
+		Name:       v.Name,
+		Mode:       updateModeOf(v),
+		Conditions: []listCondition{},
+	}
+	if target := v.Spec.TargetRef; target != nil {
+		rec.TargetAPIVersion = target.APIVersion
+		rec.TargetKind = target.Kind
+		rec.TargetName = target.Name
+	}
+	if v.Status.Recommendation != nil {
+		rec.Recommendation = len(v.Status.Recommendation.ContainerRecommendations) > 0
+	}
+	for _, cond := range v.Status.Conditions {
+		c := listCondition{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		}
+		if !cond.LastTransitionTime.IsZero() {
+			c.LastTransitionTime = cond.LastTransitionTime.UTC().Format(time.RFC3339)
+		}
+		rec.Conditions = append(rec.Conditions, c)
+	}
+	return rec
+}
+
+// status returns the status of a condition, or '---' if the VPA doesn't have it
+func (rec *listRecord) status(condition string) string {
+	for _, c := range rec.Conditions {
+		if strings.EqualFold(c.Type, condition) {
+			return c.Status
+		}
+	}
+	return "---"
+}
+
+// show checks the record against the --mode and --condition filters
+func (list *listArgs) show(rec *listRecord) bool {
+	if len(list.Modes) > 0 {
+		var found bool
+		for _, mode := range list.Modes {
+			if rec.Mode == mode.String() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, cond := range list.Conditions {
+		if name, not := strings.CutPrefix(cond, "!"); not {
+			if rec.status(name) == "True" {
+				return false
+			}
+		} else if rec.status(name) != "True" {
+			return false
+		}
+	}
+	return true
+}
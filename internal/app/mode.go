@@ -2,31 +2,284 @@ package app
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ninlil/columns"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// annotations used to record the previous mode, so a mode-change can be undone
+const (
+	annotationPreviousMode = "kubectl-vpa/previous-mode"
+	annotationChangedBy    = "kubectl-vpa/changed-by"
+	annotationChangedAt    = "kubectl-vpa/changed-at"
+	annotationExpiresAt    = "kubectl-vpa/expires-at" // when the previous mode should be restored, see reconcile
 )
 
 type modeArgs struct {
-	Mode  modeEnum `arg:"positional,required" help:"What mode to set the VPA in: Off, Initial or Auto" placeholder:"MODE"`
-	Names []string `arg:"positional,required" help:"Name(s) of the VPA-resources to modify" placeholder:"NAME"`
+	ModeText string `arg:"positional" help:"What mode to set the VPA in: Off, Initial, Recreate or Auto (not used with --undo)" placeholder:"MODE"`
+	vpaSelection
+	MinReplicas *int32        `arg:"--min-replicas" help:"Also set the minimal number of live replicas needed before the updater evicts a pod" placeholder:"N"`
+	For         time.Duration `arg:"--for" help:"Restore the previous mode after this time (i.e. '2h'), see the reconcile command" placeholder:"DURATION"`
+	Undo        bool          `arg:"--undo" help:"Restore the mode recorded by the last mode-change (same as the undo command)"`
+
+	mode modeEnum
+}
+
+// vpaSelection selects VPAs by name or in bulk, and how to change them, used by both mode and undo
+type vpaSelection struct {
+	Names      []string   `arg:"positional" help:"Name(s) of the VPA-resources to modify" placeholder:"NAME"`
+	Selector   string     `arg:"-l,--selector" help:"Select the VPAs by label-selector (i.e. 'team=foo')"`
+	All        bool       `arg:"--all" help:"Select all VPAs in the namespace (or in all namespaces with -A)"`
+	TargetKind string     `arg:"--target-kind" help:"Select the VPAs targeting a kind (i.e. 'sts' or 'rollout.argoproj.io')" placeholder:"KIND"`
+	Yes        bool       `arg:"-y,--yes" help:"Don't ask for confirmation when selecting VPAs"`
+	DryRun     dryRunEnum `arg:"--dry-run" help:"none, client or server"`
+	Diff       bool       `arg:"--diff" help:"Show the change of the spec of each VPA (always shown with --dry-run)"`
 }
 
 func (mode *modeArgs) Verify() error {
-	if len(mode.Names) == 0 {
-		return fmt.Errorf("no names specified")
+	if mode.Undo {
+		if mode.MinReplicas != nil || mode.For != 0 {
+			return fmt.Errorf("--min-replicas and --for can't be used with --undo")
+		}
+		// there is no mode to set, so the first positional is a name
+		if mode.ModeText != "" {
+			mode.Names = append([]string{mode.ModeText}, mode.Names...)
+		}
+		return mode.vpaSelection.Verify()
+	}
+
+	if mode.ModeText == "" {
+		return fmt.Errorf("no mode specified")
+	}
+	if err := mode.mode.UnmarshalText([]byte(mode.ModeText)); err != nil {
+		return err
+	}
+	if mode.MinReplicas != nil && *mode.MinReplicas < 1 {
+		return fmt.Errorf("--min-replicas must be a positive number")
+	}
+	if mode.For < 0 {
+		return fmt.Errorf("--for must be a positive duration")
+	}
+	return mode.vpaSelection.Verify()
+}
+
+func (sel *vpaSelection) Verify() error {
+	if len(sel.Names) == 0 && !sel.selecting() {
+		return fmt.Errorf("no names specified, use names or --all, --selector or --target-kind")
+	}
+	if len(sel.Names) > 0 && sel.selecting() {
+		return fmt.Errorf("names can't be combined with --all, --selector or --target-kind")
 	}
 	return nil
 }
 
+// selecting is true when the VPAs are selected in bulk instead of by name
+func (sel *vpaSelection) selecting() bool {
+	return sel.All || sel.Selector != "" || sel.TargetKind != ""
+}
+
 func (mode *modeArgs) Exec(k8 *k8client, args *cmdArgs) {
-	fmt.Printf("set mode %s\n", args.Mode.Mode)
-	for _, input := range args.Mode.Names {
-		ns, name := args.getParts(input)
+	if mode.Undo {
+		undoModes(k8, args, &mode.vpaSelection)
+		return
+	}
+
+	vpas := mode.resolve(k8, args)
+	if len(vpas) == 0 {
+		return
+	}
+	if !mode.confirmed(vpas, fmt.Sprintf("Set mode %s on %d VPA(s)?", mode.mode, len(vpas)), func(*vpa.VerticalPodAutoscaler) string {
+		return mode.mode.String()
+	}) {
+		return
+	}
+
+	fmt.Printf("set mode %s\n", mode.mode)
+	if mode.MinReplicas != nil {
+		fmt.Printf("set minReplicas %d\n", *mode.MinReplicas)
+	}
+	now := time.Now().UTC()
+	var expiresAt string
+	if mode.For > 0 {
+		expiresAt = now.Add(mode.For).Format(time.RFC3339)
+		fmt.Printf("until %s\n", expiresAt)
+	}
+	for _, v := range vpas {
+		mode.change(k8, v, func(v *vpa.VerticalPodAutoscaler) {
+			previousMode := updateModeOf(v)
+			if v.Annotations[annotationExpiresAt] != "" && v.Annotations[annotationPreviousMode] != "" {
+				// changing a temporary mode keeps the mode from before it
+				previousMode = v.Annotations[annotationPreviousMode]
+			}
+			setAnnotations(v, map[string]string{
+				annotationPreviousMode: previousMode,
+				annotationChangedBy:    k8.Username(),
+				annotationChangedAt:    now.Format(time.RFC3339),
+				annotationExpiresAt:    expiresAt,
+			})
+			setUpdateMode(v, mode.mode)
+			if mode.MinReplicas != nil {
+				v.Spec.UpdatePolicy.MinReplicas = mode.MinReplicas
+			}
+		})
+	}
+}
+
+// change applies a change to a copy of the VPA, and patches the cluster with the difference
+//
+// With --dry-run=client nothing is sent, and with --diff (or any --dry-run) the change of the spec is shown.
+// Returns false if the change failed.
+func (sel *vpaSelection) change(k8 *k8client, v *vpa.VerticalPodAutoscaler, change func(*vpa.VerticalPodAutoscaler)) bool {
+	fmt.Printf("on %s / %s: ", v.Namespace, v.Name)
+
+	after := v.DeepCopy()
+	change(after)
+	patch, err := mergePatch(v, after)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return false
+	}
+
+	switch sel.DryRun {
+	case dryRunClient:
+		fmt.Println("ok (dry run)")
+	case dryRunServer:
+		after, err = k8.PatchVPA(v.Namespace, v.Name, patch, sel.DryRun)
+		if err == nil {
+			fmt.Println("ok (server dry run)")
+		}
+	default:
+		after, err = k8.PatchVPA(v.Namespace, v.Name, patch, sel.DryRun)
+		if err == nil {
+			fmt.Println("ok")
+		}
+	}
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return false
+	}
 
-		fmt.Printf("on %s / %s: ", ns, name)
-		err := k8.PatchVPA(ns, name, "/spec/updatePolicy/updateMode", args.Mode.Mode.String())
+	if sel.Diff || sel.DryRun != dryRunNone {
+		diff, err := diffYAML(v.Spec, after.Spec)
 		if err != nil {
 			fmt.Printf("error: %s\n", err)
-		} else {
-			fmt.Println("ok")
+			return true
+		}
+		fmt.Print(diff)
+	}
+	return true
+}
+
+// resolve reads the VPAs given by name, or lists the VPAs matching --all, --selector and --target-kind
+func (sel *vpaSelection) resolve(k8 *k8client, args *cmdArgs) []*vpa.VerticalPodAutoscaler {
+	var vpas []*vpa.VerticalPodAutoscaler
+	if !sel.selecting() {
+		for _, input := range sel.Names {
+			ns, name := args.getParts(input)
+			v, err := k8.VPA(ns, name)
+			if err != nil {
+				fmt.Printf("on %s / %s: error: %s\n", ns, name, err)
+				continue
+			}
+			vpas = append(vpas, v)
+		}
+		return vpas
+	}
+
+	var kind *schema.GroupKind
+	if sel.TargetKind != "" {
+		gk, err := k8.KindFor(sel.TargetKind)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: unknown kind '%s': %v\n", sel.TargetKind, err)
+			os.Exit(1)
+		}
+		kind = &gk
+	}
+
+	list, err := k8.SelectVPAs(args.Namespace, sel.Selector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for i := range list.Items {
+		v := &list.Items[i]
+		if kind != nil {
+			target := v.Spec.TargetRef
+			if target == nil || target.Kind != kind.Kind || apiGroup(target.APIVersion) != kind.Group {
+				continue
+			}
+		}
+		vpas = append(vpas, v)
+	}
+	if len(vpas) == 0 {
+		fmt.Println("no matching VPAs found")
+	}
+	return vpas
+}
+
+// confirmed shows a preview of the VPAs selected in bulk and asks for confirmation (unless --yes),
+// VPAs given by name are always confirmed
+func (sel *vpaSelection) confirmed(vpas []*vpa.VerticalPodAutoscaler, question string, newMode func(*vpa.VerticalPodAutoscaler) string) bool {
+	if !sel.selecting() {
+		return true
+	}
+	preview(vpas, newMode)
+	if !sel.Yes && !confirm(question) {
+		fmt.Println("aborted")
+		return false
+	}
+	return true
+}
+
+// preview lists the VPAs with their target and the mode-change
+func preview(vpas []*vpa.VerticalPodAutoscaler, newMode func(*vpa.VerticalPodAutoscaler) string) {
+	cw := columns.New(os.Stdout, "< < < <")
+	cw.Headers("Namespace", "Name", "Target", "Mode")
+	cw.HeaderSeparator = true
+	for _, v := range vpas {
+		target := "---"
+		if t := v.Spec.TargetRef; t != nil {
+			target = fmt.Sprintf("%s/%s", strings.ToLower(t.Kind), t.Name)
+		}
+		cw.Write(v.Namespace, v.Name, target, fmt.Sprintf("%s -> %s", updateModeOf(v), newMode(v)))
+	}
+	cw.Sort(1, 2)
+	cw.Flush()
+}
+
+// setAnnotations sets (or with an empty value, removes) annotations
+func setAnnotations(v *vpa.VerticalPodAutoscaler, values map[string]string) {
+	for key, value := range values {
+		if value == "" {
+			delete(v.Annotations, key)
+			continue
+		}
+		if v.Annotations == nil {
+			v.Annotations = make(map[string]string)
 		}
+		v.Annotations[key] = value
+	}
+}
+
+// setUpdateMode sets the UpdateMode of a VPA, adding the update-policy if missing
+func setUpdateMode(v *vpa.VerticalPodAutoscaler, mode modeEnum) {
+	updateMode := vpa.UpdateMode(mode.String())
+	if v.Spec.UpdatePolicy == nil {
+		v.Spec.UpdatePolicy = &vpa.PodUpdatePolicy{}
+	}
+	v.Spec.UpdatePolicy.UpdateMode = &updateMode
+}
+
+// updateModeOf returns the UpdateMode of a VPA, a VPA without one defaults to Auto
+func updateModeOf(v *vpa.VerticalPodAutoscaler) string {
+	if v.Spec.UpdatePolicy != nil && v.Spec.UpdatePolicy.UpdateMode != nil {
+		return string(*v.Spec.UpdatePolicy.UpdateMode)
 	}
+	return modeAutoText
 }
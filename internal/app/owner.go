@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// wellKnownControllers are the 'Kind.group' of the controllers that the VPA always accepts as a target,
+// any other kind is only accepted when it has a scale-subresource
+var wellKnownControllers = map[string]bool{
+	"Deployment.apps":       true,
+	"ReplicaSet.apps":       true,
+	"StatefulSet.apps":      true,
+	"DaemonSet.apps":        true,
+	"Deployment.extensions": true,
+	"ReplicaSet.extensions": true,
+	"DaemonSet.extensions":  true,
+	"Job.batch":             true,
+	"CronJob.batch":         true,
+	"ReplicationController": true,
+}
+
+// listedOwners are the common kinds of owners that are listed once per namespace instead of read one by one
+var listedOwners = map[schema.GroupVersionKind]schema.GroupVersionResource{
+	appsv1.SchemeGroupVersion.WithKind(kindReplicaSet):  appsv1.SchemeGroupVersion.WithResource("replicasets"),
+	appsv1.SchemeGroupVersion.WithKind(kindDeployment):  appsv1.SchemeGroupVersion.WithResource("deployments"),
+	appsv1.SchemeGroupVersion.WithKind(kindStatefulSet): appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	appsv1.SchemeGroupVersion.WithKind(kindDaemonSet):   appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	batchv1.SchemeGroupVersion.WithKind(kindJob):        batchv1.SchemeGroupVersion.WithResource("jobs"),
+	batchv1.SchemeGroupVersion.WithKind(kindCronJob):    batchv1.SchemeGroupVersion.WithResource("cronjobs"),
+}
+
+// ownerData is the top-most controller of an object (found by following the ownerReferences)
+type ownerData struct {
+	APIVersion string
+	Kind       string
+	Name       string
+
+	// Err is set when the chain of owners could not be followed to the end,
+	// APIVersion, Kind & Name is then the last known owner
+	Err error
+}
+
+func (owner *ownerData) String() string {
+	return fmt.Sprintf("%s '%s' (%s)", owner.Kind, owner.Name, owner.APIVersion)
+}
+
+// controllerOf returns the controller-reference of the object,
+// or the first owner-reference if none is marked as controller
+func controllerOf(obj metav1.Object) *metav1.OwnerReference {
+	if ref := metav1.GetControllerOf(obj); ref != nil {
+		return ref
+	}
+	if refs := obj.GetOwnerReferences(); len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+// Owner resolves the top-most owner of an object through the API,
+// i.e. Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob
+//
+// The chain stops at the first owner that the VPA can't target (same as the VPA's controller-fetcher),
+// i.e. a StatefulSet owned by an operator's custom resource, or a mirror-pod owned by a Node.
+// An object without such owners is its own owner. Successful lookups are cached.
+func (k8 *k8client) Owner(obj metav1.Object, apiVersion, kind string) *ownerData {
+	self := &ownerData{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       obj.GetName(),
+	}
+	ref := controllerOf(obj)
+	if ref == nil {
+		return self
+	}
+
+	controller, err := k8.isController(ref.APIVersion, ref.Kind)
+	if err != nil {
+		return &ownerData{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+			Name:       ref.Name,
+			Err:        fmt.Errorf("unable to resolve owner %s %s/%s: %w", ref.Kind, obj.GetNamespace(), ref.Name, err),
+		}
+	}
+	if !controller {
+		return self
+	}
+	return k8.resolveOwner(obj.GetNamespace(), ref)
+}
+
+func (k8 *k8client) resolveOwner(ns string, ref *metav1.OwnerReference) *ownerData {
+	key := fmt.Sprintf("%s:%s@%s/%s", ref.APIVersion, ref.Kind, ns, ref.Name)
+	if owner, ok := k8.owners[key]; ok {
+		return owner
+	}
+
+	obj, err := k8.ownerMeta(ns, ref)
+	if err != nil {
+		// not cached, the owner might be found next time (i.e. during a rollout with 'compare --watch')
+		return &ownerData{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+			Name:       ref.Name,
+			Err:        fmt.Errorf("unable to resolve owner %s %s/%s: %w", ref.Kind, ns, ref.Name, err),
+		}
+	}
+
+	owner := k8.Owner(obj, ref.APIVersion, ref.Kind)
+	if owner.Err == nil {
+		if k8.owners == nil {
+			k8.owners = make(map[string]*ownerData)
+		}
+		k8.owners[key] = owner
+	}
+	return owner
+}
+
+// isController checks if the VPA accepts a kind as a target:
+// a well-known controller or any kind with a scale-subresource
+func (k8 *k8client) isController(apiVersion, kind string) (bool, error) {
+	gk := schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind()
+	if wellKnownControllers[gk.String()] {
+		return true, nil
+	}
+
+	mapping, err := k8.restMapping(apiVersion, kind)
+	if err != nil {
+		return false, err
+	}
+	resources, err := k8.discovery.ServerResourcesForGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == mapping.Resource.Resource+"/scale" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ownerMeta reads the metadata of an owner, the listed kinds are read from a listing of the whole namespace
+func (k8 *k8client) ownerMeta(ns string, ref *metav1.OwnerReference) (metav1.Object, error) {
+	gvr, ok := listedOwners[schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)]
+	if !ok {
+		return k8.ObjectMeta(ns, ref.APIVersion, ref.Kind, ref.Name)
+	}
+
+	key := fmt.Sprintf("%s@%s", gvr.Resource, ns)
+	items, ok := k8.ownerLists[key]
+	if !ok {
+		// a failed listing is stored as empty, the owners are then read one by one
+		items = make(map[string]metav1.Object)
+		list, err := k8.metaClient.Resource(gvr).Namespace(ns).List(context.Background(), metav1.ListOptions{})
+		if err == nil {
+			for i := range list.Items {
+				items[list.Items[i].Name] = &list.Items[i]
+			}
+		}
+		if k8.ownerLists == nil {
+			k8.ownerLists = make(map[string]map[string]metav1.Object)
+		}
+		k8.ownerLists[key] = items
+	}
+
+	if obj, ok := items[ref.Name]; ok {
+		return obj, nil
+	}
+	// created after the listing (i.e. a new ReplicaSet during a rollout)
+	return k8.ObjectMeta(ns, ref.APIVersion, ref.Kind, ref.Name)
+}
+
+// ObjectMeta reads the metadata of any kind of object
+func (k8 *k8client) ObjectMeta(ns, apiVersion, kind, name string) (*metav1.PartialObjectMetadata, error) {
+	mapping, err := k8.restMapping(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return k8.metaClient.Resource(mapping.Resource).Get(context.Background(), name, metav1.GetOptions{})
+	}
+	return k8.metaClient.Resource(mapping.Resource).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+type reconcileArgs struct {
+	Selector string     `arg:"-l,--selector" help:"Only reconcile the VPAs matching a label-selector (i.e. 'team=foo')"`
+	DryRun   dryRunEnum `arg:"--dry-run" help:"none, client or server"`
+	Diff     bool       `arg:"--diff" help:"Show the change of the spec of each VPA (always shown with --dry-run)"`
+}
+
+func (rec *reconcileArgs) Verify() error {
+	return nil
+}
+
+// Exec restores the previous mode of all VPAs where a temporary mode (mode --for) has expired,
+// and exits with 1 if any of them failed (to be run from a CronJob)
+func (rec *reconcileArgs) Exec(k8 *k8client, args *cmdArgs) {
+	sel := &vpaSelection{
+		All:      true,
+		Selector: rec.Selector,
+		Yes:      true,
+		DryRun:   rec.DryRun,
+		Diff:     rec.Diff,
+	}
+
+	now := time.Now()
+	var expired []*vpa.VerticalPodAutoscaler
+	var failed int
+	for _, v := range sel.resolve(k8, args) {
+		text := v.Annotations[annotationExpiresAt]
+		if text == "" {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			fmt.Printf("on %s / %s: error: invalid %s: %s\n", v.Namespace, v.Name, annotationExpiresAt, err)
+			failed++
+			continue
+		}
+		if expiresAt.After(now) {
+			if args.Debug {
+				fmt.Printf("# %s/%s expires in %s\n", v.Namespace, v.Name, expiresAt.Sub(now).Round(time.Second))
+			}
+			continue
+		}
+		expired = append(expired, v)
+	}
+
+	if len(expired) == 0 {
+		fmt.Println("no expired modes found")
+	} else {
+		failed += sel.restore(k8, expired)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
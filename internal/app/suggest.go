@@ -2,138 +2,255 @@ package app
 
 import (
 	"fmt"
-	"log"
 	"math"
-	"strconv"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
 )
 
 type suggestData struct {
 	Resources suggestResources `json:"resources"`
 }
 type suggestResources struct {
-	Requests suggestValues `json:"requests"`
-	Limits   suggestValues `json:"limits"`
+	Requests suggestValues `json:"requests" toml:"requests"`
+	Limits   suggestValues `json:"limits" toml:"limits"`
 }
 type suggestValues struct {
-	CPU    *string `json:"cpu,omitempty"`
-	Memory *string `json:"memory,omitempty"`
+	CPU    *string `json:"cpu,omitempty" yaml:"cpu,omitempty" toml:"cpu,omitempty"`
+	Memory *string `json:"memory,omitempty" yaml:"memory,omitempty" toml:"memory,omitempty"`
+}
+
+// suggestDocument is the suggestion for a VPA, one document per VPA in the output
+type suggestDocument struct {
+	VPA        suggestRef         `json:"vpa" yaml:"vpa" toml:"vpa"`
+	Target     *suggestRef        `json:"target" yaml:"target" toml:"target,omitempty"`
+	Containers []suggestContainer `json:"containers" yaml:"containers" toml:"containers"`
+}
+type suggestRef struct {
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty" toml:"apiVersion,omitempty"`
+	Kind       string `json:"kind" yaml:"kind" toml:"kind"`
+	Namespace  string `json:"namespace" yaml:"namespace" toml:"namespace"`
+	Name       string `json:"name" yaml:"name" toml:"name"`
+}
+type suggestContainer struct {
+	Name      string                 `json:"name" yaml:"name" toml:"name"`
+	Resources map[string]interface{} `json:"resources" yaml:"resources" toml:"resources"` // only the requests and limits that are set
+}
+
+// suggestDocuments is the output for toml, where all suggestions are in one document (as '[[suggestions]]')
+type suggestDocuments struct {
+	Suggestions []*suggestDocument `toml:"suggestions"`
 }
 
 type suggestArgs struct {
-	Name   string     `arg:"positional,required" help:"Name of the VPA-resource to create suggestion" placeholder:"NAME"`
-	Format formatEnum `arg:"-o,--output-format" help:"Select output format (yaml [default], json, toml)"`
+	Names     []string   `arg:"positional" help:"Name(s) of the VPA-resources to create suggestions for" placeholder:"NAME"`
+	Filenames []string   `arg:"-f,--filename,separate" help:"Read names from input file (or '-' for stdin)"`
+	Selector  string     `arg:"-l,--selector" help:"Create suggestions for the VPAs matching a label-selector (i.e. 'team=foo')"`
+	Format    formatEnum `arg:"-o,--output-format" help:"Select output format (yaml [default], json, toml)"`
+	Patch     string     `arg:"--patch" help:"Print a patch for the pod-template of the target instead: strategic, json or kustomize"`
+
+	Apply  bool       `arg:"--apply" help:"Set the suggested resources in the pod-template of the target (after showing the change)"`
+	DryRun dryRunEnum `arg:"--dry-run" help:"With --apply: none, client or server"`
+	Yes    bool       `arg:"-y,--yes" help:"With --apply: don't ask for confirmation"`
+
+	CPU    []string `arg:"--cpu,separate" help:"how to calculate cpu (i.e. 'headroom=1.2,limit=none,round=50m')" placeholder:"KEY=VALUE"`
+	Memory []string `arg:"--memory,separate" help:"how to calculate memory (i.e. 'source=upperBound,limit=request,round=64Mi')" placeholder:"KEY=VALUE"`
+
+	cpu           suggestPolicy
+	memory        suggestPolicy
+	allNamespaces bool // -A, set before Verify
 }
 
 var (
-	errNameMissing = fmt.Errorf("resource name must be specified")
+	errNameMissing = fmt.Errorf("resource name must be specified (or use --selector or -A)")
 )
 
 func (suggest *suggestArgs) Verify() error {
-	if suggest.Name == "" {
-		return errNameMissing
+	if (len(suggest.Names) > 0 || len(suggest.Filenames) > 0) && suggest.Selector != "" {
+		return fmt.Errorf("names can't be combined with --selector")
 	}
-	return nil
+	if (len(suggest.Names) > 0 || len(suggest.Filenames) > 0) && suggest.allNamespaces {
+		return fmt.Errorf("names can't be combined with -A")
+	}
+	if !suggest.Apply && (suggest.DryRun != dryRunNone || suggest.Yes) {
+		return fmt.Errorf("--dry-run and --yes can only be used with --apply")
+	}
+	var err error
+	if suggest.cpu, err = parseSuggestPolicy(corev1.ResourceCPU, suggest.CPU); err != nil {
+		return err
+	}
+	if suggest.memory, err = parseSuggestPolicy(corev1.ResourceMemory, suggest.Memory); err != nil {
+		return err
+	}
+	if suggest.Apply && suggest.Patch != "" {
+		return fmt.Errorf("--apply can't be combined with --patch")
+	}
+	if suggest.Patch != "" {
+		switch strings.ToLower(suggest.Patch) {
+		case patchStrategic, patchJSON, patchKustomize:
+			suggest.Patch = strings.ToLower(suggest.Patch)
+		default:
+			return fmt.Errorf("unknown patch: '%s', allowed values: strategic, json & kustomize", suggest.Patch)
+		}
+		return verifyFormat(suggest.Format, formatYAML, formatJSON)
+	}
+	return verifyFormat(suggest.Format, formatYAML, formatJSON, formatTOML)
 }
 
 func (suggest *suggestArgs) Exec(k8 *k8client, args *cmdArgs) {
-	ns, name := args.getParts(args.Suggest.Name)
-	vpa, err := k8.VPA(ns, name)
-	if err != nil {
-		fmt.Printf("error: %v\n", err)
-		return
+	for _, filename := range suggest.Filenames {
+		suggest.Names = append(suggest.Names, linesFromFile(filename)...)
 	}
-
-	recommend := vpa.Status.Recommendation
-	if recommend == nil {
-		log.Printf("VPA %s/%s have no recommendations (yet)", vpa.Namespace, vpa.Name)
-		return
+	if len(suggest.Names) == 0 && suggest.Selector == "" && !args.AllNamespaces {
+		fmt.Fprintf(os.Stderr, "error: %v\n", errNameMissing)
+		os.Exit(1)
 	}
 
-	yaml, err := suggest.Format.Encoder()
-	if err != nil {
-		log.Printf("yaml-encoder-error: %v", err)
-		return
-	}
-
-	for _, c := range recommend.ContainerRecommendations {
-		fmt.Printf("\n# container %s\n", c.ContainerName)
-		var data suggestData
-
-		if v, ok := c.Target["cpu"]; ok {
-			data.Resources.Requests.CPU = calcValue(v.String(), 1)
+	var skipped []string
+	var documents suggestDocuments
+	for _, v := range suggest.resolve(k8, args) {
+		recommend := v.Status.Recommendation
+		if recommend == nil || len(recommend.ContainerRecommendations) == 0 {
+			skipped = append(skipped, fmt.Sprintf("%s/%s", v.Namespace, v.Name))
+			continue
 		}
-		if v, ok := c.Target["memory"]; ok {
-			data.Resources.Requests.Memory = calcValue(v.String(), 1)
+
+		var err error
+		switch {
+		case suggest.Apply:
+			err = suggest.apply(k8, v)
+		case suggest.Patch != "":
+			err = suggest.printPatch(k8, v)
+		case suggest.Format == formatTOML:
+			documents.Suggestions = append(documents.Suggestions, suggest.suggestionOf(v))
+		default:
+			err = suggest.printSuggestion(suggest.suggestionOf(v))
 		}
-		if v, ok := c.UpperBound["cpu"]; ok {
-			data.Resources.Limits.CPU = calcValue(v.String(), 1.5)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		}
-		if v, ok := c.UpperBound["memory"]; ok {
-			data.Resources.Limits.Memory = calcValue(v.String(), 1.5)
+	}
+	if len(documents.Suggestions) > 0 {
+		if err := suggest.printSuggestion(&documents); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		}
+	}
 
-		buf, err := yaml.Encode(&data)
-		if err != nil {
-			log.Printf("yaml-encoder-error: %v", err)
-			return
-		}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d VPA(s) without recommendations (yet): %s\n", len(skipped), strings.Join(skipped, ", "))
+	}
+}
 
-		fmt.Print(string(buf))
+// resolve reads the VPAs given by name, or lists the VPAs matching --selector (in the namespace or in all namespaces with -A)
+func (suggest *suggestArgs) resolve(k8 *k8client, args *cmdArgs) []*vpa.VerticalPodAutoscaler {
+	var vpas []*vpa.VerticalPodAutoscaler
+	if len(suggest.Names) > 0 {
+		for _, input := range suggest.Names {
+			ns, name := args.getParts(input)
+			v, err := k8.VPA(ns, name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s/%s: %v\n", ns, name, err)
+				continue
+			}
+			vpas = append(vpas, v)
+		}
+		return vpas
 	}
 
-	//fmt.Printf("vpa = %s\n", vpa.Name)
+	list, err := k8.SelectVPAs(args.Namespace, suggest.Selector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	for i := range list.Items {
+		vpas = append(vpas, &list.Items[i])
+	}
+	return vpas
 }
 
-const (
-	multMi = 1024 * 1024
-)
+// suggestionOf creates the suggested resources of all containers of the VPA
+func (suggest *suggestArgs) suggestionOf(v *vpa.VerticalPodAutoscaler) *suggestDocument {
+	doc := &suggestDocument{
+		VPA: suggestRef{Kind: kindVPA, Namespace: v.Namespace, Name: v.Name},
+	}
+	if ref := v.Spec.TargetRef; ref != nil {
+		doc.Target = &suggestRef{APIVersion: ref.APIVersion, Kind: ref.Kind, Namespace: v.Namespace, Name: ref.Name}
+	}
+	for i := range v.Status.Recommendation.ContainerRecommendations {
+		c := &v.Status.Recommendation.ContainerRecommendations[i]
+		doc.Containers = append(doc.Containers, suggestContainer{
+			Name:      c.ContainerName,
+			Resources: resourcesPatch(suggest.suggestFor(c).Resources),
+		})
+	}
+	return doc
+}
 
-func calcValue(v string, scale float64) *string {
-	n, err := getValue(v)
+// printSuggestion prints a suggestion as a document (or all suggestions for toml)
+func (suggest *suggestArgs) printSuggestion(doc interface{}) error {
+	enc, err := suggest.Format.Encoder()
 	if err != nil {
-		return nil
+		return err
+	}
+	buf, err := enc.Encode(doc)
+	if err != nil {
+		return err
 	}
 
-	var suffix string
-	n *= scale
-
-	if n < 10 {
-		n *= 1000
-		suffix = "m"
-	} else {
-		if n > multMi {
-			n /= multMi
-			suffix = "Mi"
-		}
+	if suggest.Format == formatYAML {
+		fmt.Println("---")
 	}
-	txt := fmt.Sprintf("%d%s", int(math.RoundToEven(n)), suffix)
-	return &txt
+	fmt.Print(string(buf))
+	if !strings.HasSuffix(string(buf), "\n") {
+		fmt.Println()
+	}
+	return nil
 }
 
-func getValue(v string) (float64, error) {
+// suggestFor calculates the suggested resources from the recommendation of a container
+func (suggest *suggestArgs) suggestFor(c *vpa.RecommendedContainerResources) suggestData {
+	var data suggestData
+	data.Resources.Requests.CPU, data.Resources.Limits.CPU = suggest.cpu.calc(c, corev1.ResourceCPU)
+	data.Resources.Requests.Memory, data.Resources.Limits.Memory = suggest.memory.calc(c, corev1.ResourceMemory)
+	return data
+}
 
-	var p int = -1
-	for i, ch := range v {
-		if !((ch >= '0' && ch <= '9') || (ch == '.')) && p < 0 {
-			p = i
-		}
+// values returns the values that are set, keyed by resource-name
+func (values *suggestValues) values() map[string]interface{} {
+	m := make(map[string]interface{})
+	if values.CPU != nil {
+		m["cpu"] = *values.CPU
 	}
-	if p < 0 {
-		p = len(v)
+	if values.Memory != nil {
+		m["memory"] = *values.Memory
 	}
+	return m
+}
 
-	var suffix string
-	n, err := strconv.ParseFloat(v[:p], 64)
-	if p >= 0 {
-		suffix = v[p:]
+// calcValue scales a quantity and rounds it to a multiple of 'round' (at least one, but a zero stays zero),
+// the result is in the canonical form of the format (i.e. '250m', '2' or '320Mi')
+//
+// The milli-value of quantities above 9P (or 8Pi) doesn't fit in an int64, so the calculation is done in float64
+// and only values that fit are returned in milli-units.
+func calcValue(q resource.Quantity, scale float64, round int64, format resource.Format) *string {
+	n := q.AsApproximateFloat64() * 1000 * scale
+	if round > 0 && n > 0 {
+		n = math.Max(math.Round(n/float64(round)), 1) * float64(round)
 	}
 
-	switch suffix {
-	case "m":
-		n /= 1000
-	case "Mi":
-		n *= multMi
+	var result *resource.Quantity
+	switch {
+	case n < math.MaxInt64:
+		result = resource.NewMilliQuantity(int64(math.Round(n)), format)
+	case n/1000 < math.MaxInt64:
+		result = resource.NewQuantity(int64(math.Round(n/1000)), format)
+	default:
+		result = resource.NewQuantity(math.MaxInt64, format)
 	}
-
-	return n, err
+	txt := result.String()
+	return &txt
 }
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// apply sets the suggested resources in the pod-template of the target of the VPA with a JSON-patch,
+// after showing the change and asking for confirmation
+func (suggest *suggestArgs) apply(k8 *k8client, v *vpa.VerticalPodAutoscaler) error {
+	target, err := suggest.targetOf(k8, v)
+	if err != nil {
+		return err
+	}
+	obj := target.obj
+	name := fmt.Sprintf("%s %s/%s", strings.ToLower(obj.GetKind()), obj.GetNamespace(), obj.GetName())
+
+	before, after := target.resources(false), target.resources(true)
+	if reflect.DeepEqual(before, after) {
+		fmt.Printf("%s: unchanged\n", name)
+		return nil
+	}
+	diff, err := diffYAML(before, after)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s:\n%s", name, diff)
+
+	switch {
+	case suggest.DryRun == dryRunClient:
+		fmt.Printf("%s: patched (dry run)\n", name)
+		return nil
+	case suggest.DryRun == dryRunNone && !suggest.Yes && !confirm(fmt.Sprintf("Patch %s?", name)):
+		fmt.Println("aborted")
+		return nil
+	}
+
+	patch, err := json.Marshal(target.jsonPatch())
+	if err != nil {
+		return err
+	}
+	if _, err := k8.PatchObject(obj, patch, suggest.DryRun); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if suggest.DryRun == dryRunServer {
+		fmt.Printf("%s: patched (server dry run)\n", name)
+	} else {
+		fmt.Printf("%s: patched\n", name)
+	}
+	return nil
+}
+
+// resources lists the name & resources of the containers with a suggestion, before or after the suggestion is set
+func (target *suggestTarget) resources(suggested bool) map[string]interface{} {
+	lists := make(map[string]interface{})
+	for _, list := range containerLists {
+		var containers []interface{}
+		for _, c := range target.containers[list] {
+			name := containerName(c)
+			data, ok := target.suggested[name]
+			if !ok {
+				continue
+			}
+
+			resources, _ := c.(map[string]interface{})["resources"].(map[string]interface{})
+			resources = runtime.DeepCopyJSON(resources)
+			if resources == nil {
+				resources = make(map[string]interface{})
+			}
+			if suggested {
+				for kind, values := range resourcesPatch(data.Resources) {
+					current, ok := resources[kind].(map[string]interface{})
+					if !ok {
+						current = make(map[string]interface{})
+						resources[kind] = current
+					}
+					for key, value := range values.(map[string]interface{}) {
+						current[key] = value
+					}
+				}
+			}
+			containers = append(containers, map[string]interface{}{"name": name, "resources": resources})
+		}
+		if len(containers) > 0 {
+			lists[list] = containers
+		}
+	}
+	return lists
+}
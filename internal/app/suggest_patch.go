@@ -0,0 +1,268 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// patch-types for suggest --patch
+const (
+	patchStrategic = "strategic" // strategic merge-patch, for 'kubectl patch'
+	patchJSON      = "json"      // JSON-patch (RFC 6902), for 'kubectl patch --type json'
+	patchKustomize = "kustomize" // strategic merge-patch with apiVersion, kind & name, for the 'patches' of a kustomization
+)
+
+// containerLists are the lists of containers in a pod-spec
+var containerLists = []string{"initContainers", "containers"}
+
+// jsonPatchOp is an operation of a JSON-patch
+type jsonPatchOp struct {
+	Op    string      `json:"op" yaml:"op"`
+	Path  string      `json:"path" yaml:"path"`
+	Value interface{} `json:"value" yaml:"value"`
+}
+
+// suggestTarget is the pod-template of the target of a VPA, with the suggestion for each container
+type suggestTarget struct {
+	obj        *unstructured.Unstructured
+	path       []string                 // path of the pod-template
+	containers map[string][]interface{} // the containers of the pod-template, by list
+	suggested  map[string]suggestData   // by container-name
+}
+
+// printPatch prints a patch that sets the suggested resources in the pod-template of the target of the VPA
+func (suggest *suggestArgs) printPatch(k8 *k8client, v *vpa.VerticalPodAutoscaler) error {
+	target, err := suggest.targetOf(k8, v)
+	if err != nil {
+		return err
+	}
+	// only the built-in kinds have the schema needed to merge the containers by name,
+	// other kinds (i.e. a Rollout) would get their containers replaced with only name & resources
+	if suggest.Patch != patchJSON && !scheme.Scheme.Recognizes(target.obj.GroupVersionKind()) {
+		return fmt.Errorf("%s %s/%s: a %s patch only works for built-in kinds, use --patch json",
+			target.obj.GetKind(), target.obj.GetNamespace(), target.obj.GetName(), suggest.Patch)
+	}
+
+	var patch interface{}
+	switch suggest.Patch {
+	case patchJSON:
+		patch = target.jsonPatch()
+	case patchKustomize:
+		p := target.strategicPatch()
+		p["apiVersion"] = target.obj.GetAPIVersion()
+		p["kind"] = target.obj.GetKind()
+		p["metadata"] = map[string]interface{}{"name": target.obj.GetName()}
+		patch = p
+	default:
+		patch = target.strategicPatch()
+	}
+
+	enc, err := suggest.Format.Encoder()
+	if err != nil {
+		return err
+	}
+	buf, err := enc.Encode(patch)
+	if err != nil {
+		return err
+	}
+	if suggest.Format == formatYAML {
+		fmt.Println("---")
+		fmt.Printf("# %s patch for %s %s/%s\n", suggest.Patch, target.obj.GetKind(), target.obj.GetNamespace(), target.obj.GetName())
+	}
+	fmt.Print(string(buf))
+	if !strings.HasSuffix(string(buf), "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+// targetOf reads the target of the VPA and matches the recommendations with its containers
+func (suggest *suggestArgs) targetOf(k8 *k8client, v *vpa.VerticalPodAutoscaler) (*suggestTarget, error) {
+	ref := v.Spec.TargetRef
+	if ref == nil {
+		return nil, fmt.Errorf("VPA %s/%s has no targetRef", v.Namespace, v.Name)
+	}
+	if v.Status.Recommendation == nil {
+		return nil, fmt.Errorf("VPA %s/%s have no recommendations (yet)", v.Namespace, v.Name)
+	}
+
+	obj, err := k8.Object(v.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("target of VPA %s/%s: %w", v.Namespace, v.Name, err)
+	}
+
+	target := &suggestTarget{
+		obj:        obj,
+		path:       templatePath(obj.GetAPIVersion(), obj.GetKind()),
+		containers: make(map[string][]interface{}),
+		suggested:  make(map[string]suggestData),
+	}
+	if _, found, err := unstructured.NestedMap(obj.Object, target.path...); err != nil || !found {
+		return nil, fmt.Errorf("no pod-template found at '%s' in %s, add the path to a --templates file",
+			strings.Join(target.path, "."), obj.GroupVersionKind().GroupKind())
+	}
+
+	byName := make(map[string]interface{})
+	for _, list := range containerLists {
+		containers, _, err := unstructured.NestedSlice(obj.Object, append(target.path, "spec", list)...)
+		if err != nil {
+			return nil, err
+		}
+		target.containers[list] = containers
+		for _, c := range containers {
+			byName[containerName(c)] = c
+		}
+	}
+
+	for i := range v.Status.Recommendation.ContainerRecommendations {
+		rec := &v.Status.Recommendation.ContainerRecommendations[i]
+		c, ok := byName[rec.ContainerName]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "warning: container %s not found in %s %s/%s\n", rec.ContainerName, obj.GetKind(), obj.GetNamespace(), obj.GetName())
+			continue
+		}
+		data := suggest.suggestFor(rec)
+		if err := checkLimits(c, data); err != nil {
+			return nil, fmt.Errorf("%s %s/%s container %s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), rec.ContainerName, err)
+		}
+		target.suggested[rec.ContainerName] = data
+	}
+	return target, nil
+}
+
+// checkLimits fails when a suggested request is above a current limit that is kept (i.e. with limit=none),
+// as the patched pod-template would be rejected
+func checkLimits(container interface{}, data suggestData) error {
+	limits, _, _ := unstructured.NestedStringMap(container.(map[string]interface{}), "resources", "limits")
+	requests := data.Resources.Requests.values()
+	suggestedLimits := data.Resources.Limits.values()
+	for _, name := range sortedKeys(requests) {
+		if _, ok := suggestedLimits[name]; ok {
+			continue
+		}
+		current, ok := limits[name]
+		if !ok {
+			continue
+		}
+		limit, err := resource.ParseQuantity(current)
+		if err != nil {
+			return fmt.Errorf("limits.%s: %w", name, err)
+		}
+		request := resource.MustParse(requests[name].(string))
+		if request.Cmp(limit) > 0 {
+			return fmt.Errorf("the suggested %s request %s is above the current limit %s, suggest a limit (i.e. --%s limit=request) or remove the limit",
+				name, request.String(), current, name)
+		}
+	}
+	return nil
+}
+
+// strategicPatch creates a strategic merge-patch, where the containers are merged by name
+func (target *suggestTarget) strategicPatch() map[string]interface{} {
+	spec := make(map[string]interface{})
+	for _, list := range containerLists {
+		var containers []interface{}
+		for _, c := range target.containers[list] {
+			name := containerName(c)
+			data, ok := target.suggested[name]
+			if !ok {
+				continue
+			}
+			containers = append(containers, map[string]interface{}{
+				"name":      name,
+				"resources": resourcesPatch(data.Resources),
+			})
+		}
+		if len(containers) > 0 {
+			spec[list] = containers
+		}
+	}
+
+	var patch = map[string]interface{}{"spec": spec}
+	for i := len(target.path) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{target.path[i]: patch}
+	}
+	return patch
+}
+
+// jsonPatch creates a JSON-patch, where the containers are found by index and only the suggested values are added
+// (any other resources, like ephemeral-storage, are kept)
+//
+// Each container starts with a 'test' of the name at its index, so the patch fails instead of changing the wrong container
+// if the containers were reordered or renamed since they were read.
+func (target *suggestTarget) jsonPatch() []jsonPatchOp {
+	ops := []jsonPatchOp{}
+	for _, list := range containerLists {
+		for i, c := range target.containers[list] {
+			name := containerName(c)
+			data, ok := target.suggested[name]
+			if !ok {
+				continue
+			}
+			container := "/" + strings.Join(append(append([]string{}, target.path...), "spec", list, strconv.Itoa(i)), "/")
+			ops = append(ops, jsonPatchOp{Op: "test", Path: container + "/name", Value: name})
+			base := container + "/resources"
+
+			current, ok := c.(map[string]interface{})["resources"].(map[string]interface{})
+			if !ok {
+				ops = append(ops, jsonPatchOp{Op: "add", Path: base, Value: resourcesPatch(data.Resources)})
+				continue
+			}
+			for _, kind := range []string{"limits", "requests"} {
+				values := data.Resources.Requests.values()
+				if kind == "limits" {
+					values = data.Resources.Limits.values()
+				}
+				if len(values) == 0 {
+					continue
+				}
+				if _, ok := current[kind].(map[string]interface{}); !ok {
+					ops = append(ops, jsonPatchOp{Op: "add", Path: base + "/" + kind, Value: values})
+					continue
+				}
+				for _, name := range sortedKeys(values) {
+					ops = append(ops, jsonPatchOp{Op: "add", Path: base + "/" + kind + "/" + name, Value: values[name]})
+				}
+			}
+		}
+	}
+	return ops
+}
+
+// resourcesPatch is the requests and limits that are set
+func resourcesPatch(resources suggestResources) map[string]interface{} {
+	patch := make(map[string]interface{})
+	if values := resources.Requests.values(); len(values) > 0 {
+		patch["requests"] = values
+	}
+	if values := resources.Limits.values(); len(values) > 0 {
+		patch["limits"] = values
+	}
+	return patch
+}
+
+func containerName(c interface{}) string {
+	if m, ok := c.(map[string]interface{}); ok {
+		name, _ := m["name"].(string)
+		return name
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
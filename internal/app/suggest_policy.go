@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+// sources of the values in a recommendation
+const (
+	sourceTarget     = "target"
+	sourceLowerBound = "lowerBound"
+	sourceUpperBound = "upperBound"
+	sourceUncapped   = "uncapped"
+	sourceRequest    = "request" // the suggested request, only for limit-source
+)
+
+// limit-strategies for suggest
+const (
+	limitNone    = "none"    // no limit
+	limitRatio   = "ratio"   // the limit-source times the ratio
+	limitFixed   = "fixed"   // a fixed value
+	limitRequest = "request" // the same as the request
+)
+
+// suggestPolicy is how the suggestion for a resource is calculated (see --cpu & --memory)
+type suggestPolicy struct {
+	source      string  // the request is taken from target, lowerBound, upperBound or uncapped
+	headroom    float64 // the request is multiplied with this
+	limit       string  // none, ratio, fixed or request
+	limitSource string  // with limit=ratio: target, lowerBound, upperBound, uncapped or request
+	ratio       float64 // with limit=ratio
+	fixed       string  // with limit=fixed
+	round       int64   // round to a multiple of this, in milli-units
+	format      resource.Format
+}
+
+// parseSuggestPolicy reads the settings of a resource, the defaults are request=target and limit=upperBound*1.5,
+// rounded to 1m for cpu and 1Mi for memory
+func parseSuggestPolicy(name corev1.ResourceName, settings []string) (suggestPolicy, error) {
+	policy := suggestPolicy{
+		source:      sourceTarget,
+		headroom:    1,
+		limit:       limitRatio,
+		limitSource: sourceUpperBound,
+		ratio:       1.5,
+		round:       1,
+		format:      resource.DecimalSI,
+	}
+	if name == corev1.ResourceMemory {
+		policy.round = 1024 * 1024 * 1000
+		policy.format = resource.BinarySI
+	}
+
+	for _, list := range settings {
+		for _, setting := range strings.Split(list, ",") {
+			key, value, ok := strings.Cut(setting, "=")
+			if !ok {
+				return policy, fmt.Errorf("invalid --%s setting: '%s', expected KEY=VALUE", name, setting)
+			}
+			if err := policy.set(strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)); err != nil {
+				return policy, fmt.Errorf("--%s: %w", name, err)
+			}
+		}
+	}
+
+	if policy.limit == limitFixed && policy.fixed == "" {
+		return policy, fmt.Errorf("--%s: limit=fixed needs a value for 'fixed'", name)
+	}
+	return policy, nil
+}
+
+func (policy *suggestPolicy) set(key, value string) error {
+	switch key {
+	case "source":
+		source, err := parseSource(value, false)
+		if err != nil {
+			return err
+		}
+		policy.source = source
+
+	case "limit-source":
+		source, err := parseSource(value, true)
+		if err != nil {
+			return err
+		}
+		policy.limitSource = source
+
+	case "limit":
+		switch strings.ToLower(value) {
+		case limitNone, limitRatio, limitFixed, limitRequest:
+			policy.limit = strings.ToLower(value)
+		default:
+			return fmt.Errorf("unknown limit: '%s', allowed values: none, ratio, fixed & request", value)
+		}
+
+	case "headroom", "ratio":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("%s must be a positive number: '%s'", key, value)
+		}
+		if key == "headroom" {
+			policy.headroom = f
+		} else {
+			policy.ratio = f
+		}
+
+	case "fixed":
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		policy.fixed = q.String()
+		policy.limit = limitFixed
+
+	case "round":
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if q.Sign() <= 0 {
+			return fmt.Errorf("%s must be positive: '%s'", key, value)
+		}
+		policy.round = q.MilliValue()
+
+	default:
+		return fmt.Errorf("unknown key: '%s', allowed keys: source, headroom, limit, limit-source, ratio, fixed & round", key)
+	}
+	return nil
+}
+
+func parseSource(value string, limit bool) (string, error) {
+	for _, source := range []string{sourceTarget, sourceLowerBound, sourceUpperBound, sourceUncapped} {
+		if strings.EqualFold(value, source) {
+			return source, nil
+		}
+	}
+	if limit && strings.EqualFold(value, sourceRequest) {
+		return sourceRequest, nil
+	}
+	if limit {
+		return "", fmt.Errorf("unknown limit-source: '%s', allowed values: target, lowerBound, upperBound, uncapped & request", value)
+	}
+	return "", fmt.Errorf("unknown source: '%s', allowed values: target, lowerBound, upperBound & uncapped", value)
+}
+
+// calc calculates the suggested request and limit of a resource, nil when the recommendation has no value
+func (policy *suggestPolicy) calc(c *vpa.RecommendedContainerResources, name corev1.ResourceName) (request, limit *string) {
+	value, ok := sourceValue(c, policy.source, name)
+	if ok {
+		request = calcValue(value, policy.headroom, policy.round, policy.format)
+	}
+
+	switch policy.limit {
+	case limitRatio:
+		if policy.limitSource == sourceRequest {
+			if ok {
+				limit = calcValue(value, policy.headroom*policy.ratio, policy.round, policy.format)
+			}
+		} else if value, ok := sourceValue(c, policy.limitSource, name); ok {
+			limit = calcValue(value, policy.ratio, policy.round, policy.format)
+		}
+	case limitFixed:
+		limit = &policy.fixed
+	case limitRequest:
+		limit = request
+	}
+	return request, limit
+}
+
+// sourceValue returns a value from the recommendation
+func sourceValue(c *vpa.RecommendedContainerResources, source string, name corev1.ResourceName) (resource.Quantity, bool) {
+	var list corev1.ResourceList
+	switch source {
+	case sourceLowerBound:
+		list = c.LowerBound
+	case sourceUpperBound:
+		list = c.UpperBound
+	case sourceUncapped:
+		list = c.UncappedTarget
+	default:
+		list = c.Target
+	}
+	q, ok := list[name]
+	return q, ok
+}
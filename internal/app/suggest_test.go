@@ -0,0 +1,164 @@
+package app
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+func TestCalcValue(t *testing.T) {
+	const (
+		milliCPU = 1
+		mebibyte = 1024 * 1024 * 1000
+	)
+
+	tests := []struct {
+		name     string
+		value    string
+		scale    float64
+		round    int64
+		format   resource.Format
+		expected string
+	}{
+		// cpu, rounded to 1m
+		{"cpu milli", "250m", 1, milliCPU, resource.DecimalSI, "250m"},
+		{"cpu milli above one", "1500m", 1, milliCPU, resource.DecimalSI, "1500m"},
+		{"cpu cores", "2", 1, milliCPU, resource.DecimalSI, "2"},
+		{"cpu fraction", "0.5", 1, milliCPU, resource.DecimalSI, "500m"},
+		{"cpu micro", "100u", 1, milliCPU, resource.DecimalSI, "1m"},
+		{"cpu nano", "500n", 1, milliCPU, resource.DecimalSI, "1m"},
+		{"cpu nano below one milli", "400000n", 1, milliCPU, resource.DecimalSI, "1m"},
+		{"cpu kilo", "1k", 1, milliCPU, resource.DecimalSI, "1k"},
+		{"cpu mega", "1M", 1, milliCPU, resource.DecimalSI, "1M"},
+		{"cpu giga", "1G", 1, milliCPU, resource.DecimalSI, "1G"},
+		{"cpu tera", "1T", 1, milliCPU, resource.DecimalSI, "1T"},
+		{"cpu peta", "2P", 1, milliCPU, resource.DecimalSI, "2P"},
+		{"cpu exa", "1E", 1, milliCPU, resource.DecimalSI, "1E"},
+		{"cpu kibi", "1Ki", 1, milliCPU, resource.DecimalSI, "1024"},
+		{"cpu exponent", "1e3", 1, milliCPU, resource.DecimalSI, "1k"},
+		{"cpu negative exponent", "2e-3", 1, milliCPU, resource.DecimalSI, "2m"},
+		{"cpu zero", "0", 1, milliCPU, resource.DecimalSI, "0"},
+		{"cpu headroom", "250m", 1.2, milliCPU, resource.DecimalSI, "300m"},
+
+		// cpu, rounded to 50m
+		{"cpu round down", "120m", 1, 50, resource.DecimalSI, "100m"},
+		{"cpu round up", "130m", 1, 50, resource.DecimalSI, "150m"},
+		{"cpu round to at least one", "10m", 1, 50, resource.DecimalSI, "50m"},
+		{"cpu round cores", "1.2", 1, 50, resource.DecimalSI, "1200m"},
+		{"cpu round exponent", "1e-1", 1, 50, resource.DecimalSI, "100m"},
+		{"cpu round zero", "0", 1, 50, resource.DecimalSI, "0"},
+		{"cpu round headroom", "100m", 1.5, 50, resource.DecimalSI, "150m"},
+
+		// memory, rounded to 1Mi
+		{"memory kilo", "262144k", 1, mebibyte, resource.BinarySI, "250Mi"},
+		{"memory mega", "500M", 1, mebibyte, resource.BinarySI, "477Mi"},
+		{"memory giga", "1G", 1, mebibyte, resource.BinarySI, "954Mi"},
+		{"memory kibi", "512Ki", 1, mebibyte, resource.BinarySI, "1Mi"},
+		{"memory small kibi", "100Ki", 1, mebibyte, resource.BinarySI, "1Mi"},
+		{"memory mebi", "128Mi", 1, mebibyte, resource.BinarySI, "128Mi"},
+		{"memory gibi", "1Gi", 1, mebibyte, resource.BinarySI, "1Gi"},
+		{"memory fractional gibi", "1.5Gi", 1, mebibyte, resource.BinarySI, "1536Mi"},
+		{"memory tera", "1T", 1, mebibyte, resource.BinarySI, "953674Mi"},
+		{"memory tebi", "1Ti", 1, mebibyte, resource.BinarySI, "1Ti"},
+		{"memory peta", "1P", 1, mebibyte, resource.BinarySI, "953674316Mi"},
+		{"memory pebi", "1Pi", 1, mebibyte, resource.BinarySI, "1Pi"},
+		{"memory exa", "1E", 1, mebibyte, resource.BinarySI, "953674316406Mi"},
+		{"memory exbi", "1Ei", 1, mebibyte, resource.BinarySI, "1Ei"},
+		{"memory exbi above int64 milli", "4Ei", 1, mebibyte, resource.BinarySI, "4Ei"},
+		{"memory exbi headroom", "2Ei", 1.5, mebibyte, resource.BinarySI, "3Ei"},
+		{"memory exponent", "1e9", 1, mebibyte, resource.BinarySI, "954Mi"},
+		{"memory bytes", "1048576", 1, mebibyte, resource.BinarySI, "1Mi"},
+		{"memory zero", "0", 1, mebibyte, resource.BinarySI, "0"},
+		{"memory headroom", "100Mi", 1.2, mebibyte, resource.BinarySI, "120Mi"},
+
+		// memory, rounded to 64Mi
+		{"memory round up", "100Mi", 1, 64 * mebibyte, resource.BinarySI, "128Mi"},
+		{"memory round down", "150Mi", 1, 64 * mebibyte, resource.BinarySI, "128Mi"},
+		{"memory round giga", "1G", 1, 64 * mebibyte, resource.BinarySI, "960Mi"},
+		{"memory round to at least one", "10Mi", 1, 64 * mebibyte, resource.BinarySI, "64Mi"},
+		{"memory round exponent", "2e8", 1, 64 * mebibyte, resource.BinarySI, "192Mi"},
+		{"memory round zero", "0", 1, 64 * mebibyte, resource.BinarySI, "0"},
+		{"memory round gibi", "1500Mi", 1, 1024 * mebibyte, resource.BinarySI, "1Gi"},
+		{"memory round large pebi", "1.5Pi", 1, 1024 * mebibyte, resource.BinarySI, "1536Ti"},
+		{"memory round large exbi", "1537Pi", 1, 1024 * 1024 * mebibyte, resource.BinarySI, "1537Pi"},
+		{"memory round large to gibi", "1000000000000Ki", 1, 1024 * mebibyte, resource.BinarySI, "953674Gi"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := calcValue(resource.MustParse(test.value), test.scale, test.round, test.format)
+			if result == nil {
+				t.Fatalf("calcValue(%s) = nil, expected %s", test.value, test.expected)
+			}
+			if *result != test.expected {
+				t.Errorf("calcValue(%s) = %s, expected %s", test.value, *result, test.expected)
+			}
+		})
+	}
+}
+
+func TestSuggestPolicy(t *testing.T) {
+	rec := &vpa.RecommendedContainerResources{
+		ContainerName: "app",
+		Target: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("120m"),
+			corev1.ResourceMemory: resource.MustParse("262144k"),
+		},
+		UpperBound: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200m"),
+			corev1.ResourceMemory: resource.MustParse("500M"),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		resource corev1.ResourceName
+		settings []string
+		request  string // empty for no value
+		limit    string // empty for no value
+	}{
+		{"cpu defaults", corev1.ResourceCPU, nil, "120m", "300m"},
+		{"memory defaults", corev1.ResourceMemory, nil, "250Mi", "715Mi"},
+		{"cpu round", corev1.ResourceCPU, []string{"round=50m"}, "100m", "300m"},
+		{"memory round", corev1.ResourceMemory, []string{"round=64Mi"}, "256Mi", "704Mi"},
+		{"cpu no limit", corev1.ResourceCPU, []string{"limit=none"}, "120m", ""},
+		{"cpu limit from request", corev1.ResourceCPU, []string{"headroom=1.5,limit-source=request,ratio=2"}, "180m", "360m"},
+		{"memory limit is request", corev1.ResourceMemory, []string{"source=upperBound", "limit=request"}, "477Mi", "477Mi"},
+		{"memory fixed limit", corev1.ResourceMemory, []string{"fixed=1G"}, "250Mi", "1G"},
+		{"missing source", corev1.ResourceCPU, []string{"source=uncapped,limit-source=uncapped"}, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := parseSuggestPolicy(test.resource, test.settings)
+			if err != nil {
+				t.Fatalf("parseSuggestPolicy(%v): %v", test.settings, err)
+			}
+			request, limit := policy.calc(rec, test.resource)
+			if text := valueText(request); text != test.request {
+				t.Errorf("request = %s, expected %s", text, test.request)
+			}
+			if text := valueText(limit); text != test.limit {
+				t.Errorf("limit = %s, expected %s", text, test.limit)
+			}
+		})
+	}
+}
+
+func TestSuggestPolicyErrors(t *testing.T) {
+	for _, setting := range []string{"round=0", "round=-1m", "round=abc", "source=request", "limit=some", "unknown=1"} {
+		if _, err := parseSuggestPolicy(corev1.ResourceCPU, []string{setting}); err == nil {
+			t.Errorf("parseSuggestPolicy(%s) expected an error", setting)
+		}
+	}
+}
+
+func valueText(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/mickep76/encoding"
+)
+
+// defaultTemplatePath is used for kinds not found in templatePaths
+const defaultTemplatePath = "spec.template"
+
+// templatePaths maps a 'Kind.group' to the path of the pod-template within an object of that kind
+//
+// More kinds can be added with a templates-file (see loadTemplatePaths)
+var templatePaths = map[string]string{
+	"Deployment.apps":                    "spec.template",
+	"StatefulSet.apps":                   "spec.template",
+	"DaemonSet.apps":                     "spec.template",
+	"ReplicaSet.apps":                    "spec.template",
+	"ReplicationController":              "spec.template",
+	"Job.batch":                          "spec.template",
+	"CronJob.batch":                      "spec.jobTemplate.spec.template",
+	"Rollout.argoproj.io":                "spec.template",
+	"DeploymentConfig.apps.openshift.io": "spec.template",
+}
+
+type templatesFile struct {
+	Templates map[string]string `yaml:"templates"`
+}
+
+// loadTemplatePaths adds the template-paths from a yaml-file to the registry
+//
+// Example:
+//
+//	templates:
+//	  CloneSet.apps.kruise.io: spec.template
+func loadTemplatePaths(filename string) error {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	yaml, err := encoding.NewCodec("yaml")
+	if err != nil {
+		return err
+	}
+
+	var file templatesFile
+	if err := yaml.Decode(buf, &file); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	for kind, path := range file.Templates {
+		templatePaths[kind] = path
+	}
+	return nil
+}
+
+// templatePath returns the path of the pod-template for a kind of workload
+func templatePath(apiVersion, kind string) []string {
+	gk := schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind()
+	path, ok := templatePaths[gk.String()]
+	if !ok {
+		path = defaultTemplatePath
+	}
+	return strings.Split(path, ".")
+}
+
+// podTemplate extracts the pod-template from any kind of workload using the registered template-path
+func podTemplate(obj *unstructured.Unstructured) (*corev1.PodTemplateSpec, error) {
+	path := templatePath(obj.GetAPIVersion(), obj.GetKind())
+	fields, found, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no pod-template found at '%s' in %s, add the path to a --templates file",
+			strings.Join(path, "."), obj.GroupVersionKind().GroupKind())
+	}
+
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
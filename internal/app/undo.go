@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+
+	vpa "github.com/ninlil/kubectl-vpa/internal/vpa_v1"
+)
+
+type undoArgs struct {
+	vpaSelection
+}
+
+func (undo *undoArgs) Exec(k8 *k8client, args *cmdArgs) {
+	undoModes(k8, args, &undo.vpaSelection)
+}
+
+// undoModes restores the mode recorded in the annotations by the last mode-change, and removes the annotations
+func undoModes(k8 *k8client, args *cmdArgs, sel *vpaSelection) {
+	var vpas []*vpa.VerticalPodAutoscaler
+	for _, v := range sel.resolve(k8, args) {
+		if v.Annotations[annotationPreviousMode] != "" {
+			vpas = append(vpas, v)
+		} else if !sel.selecting() {
+			fmt.Printf("on %s / %s: nothing to undo\n", v.Namespace, v.Name)
+		}
+	}
+	if len(vpas) == 0 {
+		if sel.selecting() {
+			fmt.Println("no VPAs with a recorded mode-change found")
+		}
+		return
+	}
+
+	if !sel.confirmed(vpas, fmt.Sprintf("Restore the previous mode on %d VPA(s)?", len(vpas)), previousModeOf) {
+		return
+	}
+	sel.restore(k8, vpas)
+}
+
+// restore sets the VPAs to their previous mode and removes the annotations, returns the number of failed VPAs
+func (sel *vpaSelection) restore(k8 *k8client, vpas []*vpa.VerticalPodAutoscaler) int {
+	var failed int
+	for _, v := range vpas {
+		var mode modeEnum
+		if err := mode.UnmarshalText([]byte(previousModeOf(v))); err != nil {
+			fmt.Printf("on %s / %s: error: %s\n", v.Namespace, v.Name, err)
+			failed++
+			continue
+		}
+
+		ok := sel.change(k8, v, func(v *vpa.VerticalPodAutoscaler) {
+			setAnnotations(v, map[string]string{
+				annotationPreviousMode: "",
+				annotationChangedBy:    "",
+				annotationChangedAt:    "",
+				annotationExpiresAt:    "",
+			})
+			setUpdateMode(v, mode)
+		})
+		if !ok {
+			failed++
+		}
+	}
+	return failed
+}
+
+func previousModeOf(v *vpa.VerticalPodAutoscaler) string {
+	return v.Annotations[annotationPreviousMode]
+}
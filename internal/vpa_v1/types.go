@@ -95,6 +95,12 @@ type PodUpdatePolicy struct {
 	// The default is 'Auto'.
 	// +optional
 	UpdateMode *UpdateMode `json:"updateMode,omitempty" protobuf:"bytes,1,opt,name=updateMode"`
+
+	// Minimal number of replicas which need to be alive for Updater to attempt
+	// pod eviction (pending other checks like PDB). Only positive values are
+	// allowed. Overrides global '--min-replicas' flag.
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty" protobuf:"varint,2,opt,name=minReplicas"`
 }
 
 // UpdateMode controls when autoscaler applies changes to the pod resoures.